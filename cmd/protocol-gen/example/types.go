@@ -0,0 +1,66 @@
+// Package example holds structs with generated protocol methods, used to
+// test cmd/protocol-gen against the generic codec.
+package example
+
+import "time"
+
+//go:generate go run github.com/protocol/db-integration/cmd/protocol-gen types.go
+
+// Status is sent as a string.
+type Status string
+
+// Blob is sent as bytes.
+type Blob []byte
+
+// Labels is sent as a DataInput of strings.
+type Labels []string
+
+//protocol:generate
+type Address struct {
+	Street string `proto:"street"`
+	Zip    int32  `proto:"zip,omitempty"`
+}
+
+// Profile holds a slice, so it cannot be compared with Profile{}.
+//
+//protocol:generate
+type Profile struct {
+	Bio    string   `proto:"bio"`
+	Links  []string `proto:"links"`
+	visits map[string]int
+}
+
+//protocol:generate
+type User struct {
+	ID       int64         `proto:"id"`
+	Name     string        `proto:"name"`
+	Status   Status        `proto:"status"`
+	Age      uint8         `proto:"age"`
+	Level    int16         `proto:"level"`
+	Count    int           `proto:"count"`
+	Quota    uint          `proto:"quota,omitempty"`
+	Score    float64       `proto:"score"`
+	Ratio    float32       `proto:"ratio,omitempty"`
+	Admin    bool          `proto:"admin"`
+	Email    string        `proto:"email,omitempty"`
+	Avatar   []byte        `proto:"avatar"`
+	Key      Blob          `proto:"key,omitempty"`
+	Scores   []int32       `proto:"scores"`
+	Totals   []int64       `proto:"totals,omitempty"`
+	Weights  []float64     `proto:"weights,omitempty"`
+	Flags    []bool        `proto:"flags,omitempty"`
+	Tags     []string      `proto:"tags"`
+	Labels   Labels        `proto:"labels,omitempty"`
+	Home     *Address      `proto:"home"`
+	Work     *Address      `proto:"work,omitempty"`
+	Previous []Address     `proto:"previous"`
+	Backup   Address       `proto:"backup"`
+	Profile  Profile       `proto:"profile,omitempty"`
+	Nickname *string       `proto:"nickname"`
+	Matrix   [][]int16     `proto:"matrix,omitempty"`
+	Created  time.Time     `proto:"created"`
+	Timeout  time.Duration `proto:"timeout"`
+	Manager  *User         `proto:"manager,omitempty"`
+	Cache    []byte        `proto:"-"`
+	internal int
+}
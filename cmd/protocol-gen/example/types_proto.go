@@ -0,0 +1,865 @@
+// Code generated by protocol-gen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/protocol/db-integration/protocol"
+)
+
+// MarshalProto appends the encoding of x to dst, in the format of
+// protocol.Marshal.
+func (x *Address) MarshalProto(dst []byte) []byte {
+	var names protocol.NameTable
+	return x.appendProto(dst, &names)
+}
+
+func (x *Address) appendProto(dst []byte, names *protocol.NameTable) []byte {
+	n := 1
+	if x.Zip != 0 {
+		n++
+	}
+	dst = protocol.AppendRecordHeader(dst, names, "Address", n)
+	dst = protocol.AppendFieldName(dst, names, "street")
+	dst = protocol.AppendString(dst, x.Street)
+	if x.Zip != 0 {
+		dst = protocol.AppendFieldName(dst, names, "zip")
+		dst = protocol.AppendInt32(dst, x.Zip)
+	}
+	return dst
+}
+
+// UnmarshalProto decodes a Record written by MarshalProto or
+// protocol.Marshal into x.
+func (x *Address) UnmarshalProto(data []byte) error {
+	r := protocol.NewReader(data)
+	if err := x.readProto(r); err != nil {
+		return fmt.Errorf("protocol: unmarshal Address: %w", err)
+	}
+	return r.Done()
+}
+
+func (x *Address) readProto(r *protocol.Reader) error {
+	*x = Address{}
+	if r.ReadNull() {
+		return nil
+	}
+	n, err := r.ReadRecordHeader()
+	if err != nil {
+		return err
+	}
+	var seen [2]bool
+	for i := 0; i < n; i++ {
+		name, err := r.ReadFieldName()
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "street":
+			if seen[0] {
+				return fmt.Errorf("field street repeated in record")
+			}
+			seen[0] = true
+			{
+				v, err := r.ReadString()
+				if err != nil {
+					return fmt.Errorf("field street: %w", err)
+				}
+				x.Street = v
+			}
+		case "zip":
+			if seen[1] {
+				return fmt.Errorf("field zip repeated in record")
+			}
+			seen[1] = true
+			{
+				v, err := r.ReadInt(32)
+				if err != nil {
+					return fmt.Errorf("field zip: %w", err)
+				}
+				x.Zip = int32(v)
+			}
+		default:
+			return fmt.Errorf("record field %q has no matching field in Address", name)
+		}
+	}
+	r.EndRecord()
+	if !seen[0] {
+		return fmt.Errorf("field street missing from record")
+	}
+	return nil
+}
+
+// MarshalProto appends the encoding of x to dst, in the format of
+// protocol.Marshal.
+func (x *Profile) MarshalProto(dst []byte) []byte {
+	var names protocol.NameTable
+	return x.appendProto(dst, &names)
+}
+
+func (x *Profile) appendProto(dst []byte, names *protocol.NameTable) []byte {
+	n := 2
+	dst = protocol.AppendRecordHeader(dst, names, "Profile", n)
+	dst = protocol.AppendFieldName(dst, names, "bio")
+	dst = protocol.AppendString(dst, x.Bio)
+	dst = protocol.AppendFieldName(dst, names, "links")
+	if x.Links == nil {
+		dst = protocol.AppendNull(dst)
+	} else {
+		dst = protocol.AppendListHeader(dst, len(x.Links))
+		for i0 := range x.Links {
+			dst = protocol.AppendString(dst, x.Links[i0])
+		}
+	}
+	return dst
+}
+
+// UnmarshalProto decodes a Record written by MarshalProto or
+// protocol.Marshal into x.
+func (x *Profile) UnmarshalProto(data []byte) error {
+	r := protocol.NewReader(data)
+	if err := x.readProto(r); err != nil {
+		return fmt.Errorf("protocol: unmarshal Profile: %w", err)
+	}
+	return r.Done()
+}
+
+func (x *Profile) readProto(r *protocol.Reader) error {
+	*x = Profile{}
+	if r.ReadNull() {
+		return nil
+	}
+	n, err := r.ReadRecordHeader()
+	if err != nil {
+		return err
+	}
+	var seen [2]bool
+	for i := 0; i < n; i++ {
+		name, err := r.ReadFieldName()
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "bio":
+			if seen[0] {
+				return fmt.Errorf("field bio repeated in record")
+			}
+			seen[0] = true
+			{
+				v, err := r.ReadString()
+				if err != nil {
+					return fmt.Errorf("field bio: %w", err)
+				}
+				x.Bio = v
+			}
+		case "links":
+			if seen[1] {
+				return fmt.Errorf("field links repeated in record")
+			}
+			seen[1] = true
+			if r.ReadNull() {
+				x.Links = nil
+			} else {
+				n0, err := r.ReadListHeader()
+				if err != nil {
+					return fmt.Errorf("field links: %w", err)
+				}
+				x.Links = make([]string, n0)
+				for i0 := range x.Links {
+					{
+						v, err := r.ReadString()
+						if err != nil {
+							return fmt.Errorf("field links: %w", err)
+						}
+						x.Links[i0] = v
+					}
+				}
+				r.EndList()
+			}
+		default:
+			return fmt.Errorf("record field %q has no matching field in Profile", name)
+		}
+	}
+	r.EndRecord()
+	if !seen[0] {
+		return fmt.Errorf("field bio missing from record")
+	}
+	if !seen[1] {
+		return fmt.Errorf("field links missing from record")
+	}
+	return nil
+}
+
+// MarshalProto appends the encoding of x to dst, in the format of
+// protocol.Marshal.
+func (x *User) MarshalProto(dst []byte) []byte {
+	var names protocol.NameTable
+	return x.appendProto(dst, &names)
+}
+
+func (x *User) appendProto(dst []byte, names *protocol.NameTable) []byte {
+	n := 17
+	if x.Quota != 0 {
+		n++
+	}
+	if math.Float32bits(x.Ratio) != 0 {
+		n++
+	}
+	if x.Email != "" {
+		n++
+	}
+	if x.Key != nil {
+		n++
+	}
+	if x.Totals != nil {
+		n++
+	}
+	if x.Weights != nil {
+		n++
+	}
+	if x.Flags != nil {
+		n++
+	}
+	if x.Labels != nil {
+		n++
+	}
+	if x.Work != nil {
+		n++
+	}
+	if x.Profile.Bio != "" || x.Profile.Links != nil || x.Profile.visits != nil {
+		n++
+	}
+	if x.Matrix != nil {
+		n++
+	}
+	if x.Manager != nil {
+		n++
+	}
+	dst = protocol.AppendRecordHeader(dst, names, "User", n)
+	dst = protocol.AppendFieldName(dst, names, "id")
+	dst = protocol.AppendInt64(dst, x.ID)
+	dst = protocol.AppendFieldName(dst, names, "name")
+	dst = protocol.AppendString(dst, x.Name)
+	dst = protocol.AppendFieldName(dst, names, "status")
+	dst = protocol.AppendString(dst, string(x.Status))
+	dst = protocol.AppendFieldName(dst, names, "age")
+	dst = protocol.AppendUint64(dst, uint64(x.Age))
+	dst = protocol.AppendFieldName(dst, names, "level")
+	dst = protocol.AppendInt32(dst, int32(x.Level))
+	dst = protocol.AppendFieldName(dst, names, "count")
+	dst = protocol.AppendInt64(dst, int64(x.Count))
+	if x.Quota != 0 {
+		dst = protocol.AppendFieldName(dst, names, "quota")
+		dst = protocol.AppendUint64(dst, uint64(x.Quota))
+	}
+	dst = protocol.AppendFieldName(dst, names, "score")
+	dst = protocol.AppendFloat64(dst, x.Score)
+	if math.Float32bits(x.Ratio) != 0 {
+		dst = protocol.AppendFieldName(dst, names, "ratio")
+		dst = protocol.AppendFloat32(dst, x.Ratio)
+	}
+	dst = protocol.AppendFieldName(dst, names, "admin")
+	dst = protocol.AppendBool(dst, x.Admin)
+	if x.Email != "" {
+		dst = protocol.AppendFieldName(dst, names, "email")
+		dst = protocol.AppendString(dst, x.Email)
+	}
+	dst = protocol.AppendFieldName(dst, names, "avatar")
+	dst = protocol.AppendBytes(dst, x.Avatar)
+	if x.Key != nil {
+		dst = protocol.AppendFieldName(dst, names, "key")
+		dst = protocol.AppendBytes(dst, []byte(x.Key))
+	}
+	dst = protocol.AppendFieldName(dst, names, "scores")
+	dst = protocol.AppendInt32Array(dst, x.Scores)
+	if x.Totals != nil {
+		dst = protocol.AppendFieldName(dst, names, "totals")
+		dst = protocol.AppendInt64Array(dst, x.Totals)
+	}
+	if x.Weights != nil {
+		dst = protocol.AppendFieldName(dst, names, "weights")
+		dst = protocol.AppendFloat64Array(dst, x.Weights)
+	}
+	if x.Flags != nil {
+		dst = protocol.AppendFieldName(dst, names, "flags")
+		dst = protocol.AppendBoolArray(dst, x.Flags)
+	}
+	dst = protocol.AppendFieldName(dst, names, "tags")
+	if x.Tags == nil {
+		dst = protocol.AppendNull(dst)
+	} else {
+		dst = protocol.AppendListHeader(dst, len(x.Tags))
+		for i0 := range x.Tags {
+			dst = protocol.AppendString(dst, x.Tags[i0])
+		}
+	}
+	if x.Labels != nil {
+		dst = protocol.AppendFieldName(dst, names, "labels")
+		if x.Labels == nil {
+			dst = protocol.AppendNull(dst)
+		} else {
+			dst = protocol.AppendListHeader(dst, len(x.Labels))
+			for i0 := range x.Labels {
+				dst = protocol.AppendString(dst, x.Labels[i0])
+			}
+		}
+	}
+	dst = protocol.AppendFieldName(dst, names, "home")
+	if x.Home == nil {
+		dst = protocol.AppendNull(dst)
+	} else {
+		dst = x.Home.appendProto(dst, names)
+	}
+	if x.Work != nil {
+		dst = protocol.AppendFieldName(dst, names, "work")
+		if x.Work == nil {
+			dst = protocol.AppendNull(dst)
+		} else {
+			dst = x.Work.appendProto(dst, names)
+		}
+	}
+	dst = protocol.AppendFieldName(dst, names, "previous")
+	if x.Previous == nil {
+		dst = protocol.AppendNull(dst)
+	} else {
+		dst = protocol.AppendListHeader(dst, len(x.Previous))
+		for i0 := range x.Previous {
+			dst = x.Previous[i0].appendProto(dst, names)
+		}
+	}
+	dst = protocol.AppendFieldName(dst, names, "backup")
+	dst = x.Backup.appendProto(dst, names)
+	if x.Profile.Bio != "" || x.Profile.Links != nil || x.Profile.visits != nil {
+		dst = protocol.AppendFieldName(dst, names, "profile")
+		dst = x.Profile.appendProto(dst, names)
+	}
+	dst = protocol.AppendFieldName(dst, names, "nickname")
+	if x.Nickname == nil {
+		dst = protocol.AppendNull(dst)
+	} else {
+		dst = protocol.AppendString(dst, *x.Nickname)
+	}
+	if x.Matrix != nil {
+		dst = protocol.AppendFieldName(dst, names, "matrix")
+		if x.Matrix == nil {
+			dst = protocol.AppendNull(dst)
+		} else {
+			dst = protocol.AppendListHeader(dst, len(x.Matrix))
+			for i0 := range x.Matrix {
+				if x.Matrix[i0] == nil {
+					dst = protocol.AppendNull(dst)
+				} else {
+					dst = protocol.AppendListHeader(dst, len(x.Matrix[i0]))
+					for i1 := range x.Matrix[i0] {
+						dst = protocol.AppendInt32(dst, int32(x.Matrix[i0][i1]))
+					}
+				}
+			}
+		}
+	}
+	dst = protocol.AppendFieldName(dst, names, "created")
+	dst = protocol.AppendTime(dst, x.Created)
+	dst = protocol.AppendFieldName(dst, names, "timeout")
+	dst = protocol.AppendDuration(dst, x.Timeout)
+	if x.Manager != nil {
+		dst = protocol.AppendFieldName(dst, names, "manager")
+		if x.Manager == nil {
+			dst = protocol.AppendNull(dst)
+		} else {
+			dst = x.Manager.appendProto(dst, names)
+		}
+	}
+	return dst
+}
+
+// UnmarshalProto decodes a Record written by MarshalProto or
+// protocol.Marshal into x.
+func (x *User) UnmarshalProto(data []byte) error {
+	r := protocol.NewReader(data)
+	if err := x.readProto(r); err != nil {
+		return fmt.Errorf("protocol: unmarshal User: %w", err)
+	}
+	return r.Done()
+}
+
+func (x *User) readProto(r *protocol.Reader) error {
+	*x = User{}
+	if r.ReadNull() {
+		return nil
+	}
+	n, err := r.ReadRecordHeader()
+	if err != nil {
+		return err
+	}
+	var seen [29]bool
+	for i := 0; i < n; i++ {
+		name, err := r.ReadFieldName()
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "id":
+			if seen[0] {
+				return fmt.Errorf("field id repeated in record")
+			}
+			seen[0] = true
+			{
+				v, err := r.ReadInt(64)
+				if err != nil {
+					return fmt.Errorf("field id: %w", err)
+				}
+				x.ID = v
+			}
+		case "name":
+			if seen[1] {
+				return fmt.Errorf("field name repeated in record")
+			}
+			seen[1] = true
+			{
+				v, err := r.ReadString()
+				if err != nil {
+					return fmt.Errorf("field name: %w", err)
+				}
+				x.Name = v
+			}
+		case "status":
+			if seen[2] {
+				return fmt.Errorf("field status repeated in record")
+			}
+			seen[2] = true
+			{
+				v, err := r.ReadString()
+				if err != nil {
+					return fmt.Errorf("field status: %w", err)
+				}
+				x.Status = Status(v)
+			}
+		case "age":
+			if seen[3] {
+				return fmt.Errorf("field age repeated in record")
+			}
+			seen[3] = true
+			{
+				v, err := r.ReadUint(8)
+				if err != nil {
+					return fmt.Errorf("field age: %w", err)
+				}
+				x.Age = uint8(v)
+			}
+		case "level":
+			if seen[4] {
+				return fmt.Errorf("field level repeated in record")
+			}
+			seen[4] = true
+			{
+				v, err := r.ReadInt(16)
+				if err != nil {
+					return fmt.Errorf("field level: %w", err)
+				}
+				x.Level = int16(v)
+			}
+		case "count":
+			if seen[5] {
+				return fmt.Errorf("field count repeated in record")
+			}
+			seen[5] = true
+			{
+				v, err := r.ReadInt(strconv.IntSize)
+				if err != nil {
+					return fmt.Errorf("field count: %w", err)
+				}
+				x.Count = int(v)
+			}
+		case "quota":
+			if seen[6] {
+				return fmt.Errorf("field quota repeated in record")
+			}
+			seen[6] = true
+			{
+				v, err := r.ReadUint(strconv.IntSize)
+				if err != nil {
+					return fmt.Errorf("field quota: %w", err)
+				}
+				x.Quota = uint(v)
+			}
+		case "score":
+			if seen[7] {
+				return fmt.Errorf("field score repeated in record")
+			}
+			seen[7] = true
+			{
+				v, err := r.ReadFloat64()
+				if err != nil {
+					return fmt.Errorf("field score: %w", err)
+				}
+				x.Score = v
+			}
+		case "ratio":
+			if seen[8] {
+				return fmt.Errorf("field ratio repeated in record")
+			}
+			seen[8] = true
+			{
+				v, err := r.ReadFloat32()
+				if err != nil {
+					return fmt.Errorf("field ratio: %w", err)
+				}
+				x.Ratio = v
+			}
+		case "admin":
+			if seen[9] {
+				return fmt.Errorf("field admin repeated in record")
+			}
+			seen[9] = true
+			{
+				v, err := r.ReadBool()
+				if err != nil {
+					return fmt.Errorf("field admin: %w", err)
+				}
+				x.Admin = v
+			}
+		case "email":
+			if seen[10] {
+				return fmt.Errorf("field email repeated in record")
+			}
+			seen[10] = true
+			{
+				v, err := r.ReadString()
+				if err != nil {
+					return fmt.Errorf("field email: %w", err)
+				}
+				x.Email = v
+			}
+		case "avatar":
+			if seen[11] {
+				return fmt.Errorf("field avatar repeated in record")
+			}
+			seen[11] = true
+			{
+				v, err := r.ReadBytes()
+				if err != nil {
+					return fmt.Errorf("field avatar: %w", err)
+				}
+				x.Avatar = v
+			}
+		case "key":
+			if seen[12] {
+				return fmt.Errorf("field key repeated in record")
+			}
+			seen[12] = true
+			{
+				v, err := r.ReadBytes()
+				if err != nil {
+					return fmt.Errorf("field key: %w", err)
+				}
+				x.Key = Blob(v)
+			}
+		case "scores":
+			if seen[13] {
+				return fmt.Errorf("field scores repeated in record")
+			}
+			seen[13] = true
+			{
+				v, err := r.ReadInt32Array()
+				if err != nil {
+					return fmt.Errorf("field scores: %w", err)
+				}
+				x.Scores = v
+			}
+		case "totals":
+			if seen[14] {
+				return fmt.Errorf("field totals repeated in record")
+			}
+			seen[14] = true
+			{
+				v, err := r.ReadInt64Array()
+				if err != nil {
+					return fmt.Errorf("field totals: %w", err)
+				}
+				x.Totals = v
+			}
+		case "weights":
+			if seen[15] {
+				return fmt.Errorf("field weights repeated in record")
+			}
+			seen[15] = true
+			{
+				v, err := r.ReadFloat64Array()
+				if err != nil {
+					return fmt.Errorf("field weights: %w", err)
+				}
+				x.Weights = v
+			}
+		case "flags":
+			if seen[16] {
+				return fmt.Errorf("field flags repeated in record")
+			}
+			seen[16] = true
+			{
+				v, err := r.ReadBoolArray()
+				if err != nil {
+					return fmt.Errorf("field flags: %w", err)
+				}
+				x.Flags = v
+			}
+		case "tags":
+			if seen[17] {
+				return fmt.Errorf("field tags repeated in record")
+			}
+			seen[17] = true
+			if r.ReadNull() {
+				x.Tags = nil
+			} else {
+				n0, err := r.ReadListHeader()
+				if err != nil {
+					return fmt.Errorf("field tags: %w", err)
+				}
+				x.Tags = make([]string, n0)
+				for i0 := range x.Tags {
+					{
+						v, err := r.ReadString()
+						if err != nil {
+							return fmt.Errorf("field tags: %w", err)
+						}
+						x.Tags[i0] = v
+					}
+				}
+				r.EndList()
+			}
+		case "labels":
+			if seen[18] {
+				return fmt.Errorf("field labels repeated in record")
+			}
+			seen[18] = true
+			if r.ReadNull() {
+				x.Labels = nil
+			} else {
+				n0, err := r.ReadListHeader()
+				if err != nil {
+					return fmt.Errorf("field labels: %w", err)
+				}
+				x.Labels = make(Labels, n0)
+				for i0 := range x.Labels {
+					{
+						v, err := r.ReadString()
+						if err != nil {
+							return fmt.Errorf("field labels: %w", err)
+						}
+						x.Labels[i0] = v
+					}
+				}
+				r.EndList()
+			}
+		case "home":
+			if seen[19] {
+				return fmt.Errorf("field home repeated in record")
+			}
+			seen[19] = true
+			if r.ReadNull() {
+				x.Home = nil
+			} else {
+				x.Home = new(Address)
+				if err := x.Home.readProto(r); err != nil {
+					return fmt.Errorf("field home: %w", err)
+				}
+			}
+		case "work":
+			if seen[20] {
+				return fmt.Errorf("field work repeated in record")
+			}
+			seen[20] = true
+			if r.ReadNull() {
+				x.Work = nil
+			} else {
+				x.Work = new(Address)
+				if err := x.Work.readProto(r); err != nil {
+					return fmt.Errorf("field work: %w", err)
+				}
+			}
+		case "previous":
+			if seen[21] {
+				return fmt.Errorf("field previous repeated in record")
+			}
+			seen[21] = true
+			if r.ReadNull() {
+				x.Previous = nil
+			} else {
+				n0, err := r.ReadListHeader()
+				if err != nil {
+					return fmt.Errorf("field previous: %w", err)
+				}
+				x.Previous = make([]Address, n0)
+				for i0 := range x.Previous {
+					if err := x.Previous[i0].readProto(r); err != nil {
+						return fmt.Errorf("field previous: %w", err)
+					}
+				}
+				r.EndList()
+			}
+		case "backup":
+			if seen[22] {
+				return fmt.Errorf("field backup repeated in record")
+			}
+			seen[22] = true
+			if err := x.Backup.readProto(r); err != nil {
+				return fmt.Errorf("field backup: %w", err)
+			}
+		case "profile":
+			if seen[23] {
+				return fmt.Errorf("field profile repeated in record")
+			}
+			seen[23] = true
+			if err := x.Profile.readProto(r); err != nil {
+				return fmt.Errorf("field profile: %w", err)
+			}
+		case "nickname":
+			if seen[24] {
+				return fmt.Errorf("field nickname repeated in record")
+			}
+			seen[24] = true
+			if r.ReadNull() {
+				x.Nickname = nil
+			} else {
+				x.Nickname = new(string)
+				{
+					v, err := r.ReadString()
+					if err != nil {
+						return fmt.Errorf("field nickname: %w", err)
+					}
+					*x.Nickname = v
+				}
+			}
+		case "matrix":
+			if seen[25] {
+				return fmt.Errorf("field matrix repeated in record")
+			}
+			seen[25] = true
+			if r.ReadNull() {
+				x.Matrix = nil
+			} else {
+				n0, err := r.ReadListHeader()
+				if err != nil {
+					return fmt.Errorf("field matrix: %w", err)
+				}
+				x.Matrix = make([][]int16, n0)
+				for i0 := range x.Matrix {
+					if r.ReadNull() {
+						x.Matrix[i0] = nil
+					} else {
+						n1, err := r.ReadListHeader()
+						if err != nil {
+							return fmt.Errorf("field matrix: %w", err)
+						}
+						x.Matrix[i0] = make([]int16, n1)
+						for i1 := range x.Matrix[i0] {
+							{
+								v, err := r.ReadInt(16)
+								if err != nil {
+									return fmt.Errorf("field matrix: %w", err)
+								}
+								x.Matrix[i0][i1] = int16(v)
+							}
+						}
+						r.EndList()
+					}
+				}
+				r.EndList()
+			}
+		case "created":
+			if seen[26] {
+				return fmt.Errorf("field created repeated in record")
+			}
+			seen[26] = true
+			{
+				v, err := r.ReadTime()
+				if err != nil {
+					return fmt.Errorf("field created: %w", err)
+				}
+				x.Created = v
+			}
+		case "timeout":
+			if seen[27] {
+				return fmt.Errorf("field timeout repeated in record")
+			}
+			seen[27] = true
+			{
+				v, err := r.ReadDuration()
+				if err != nil {
+					return fmt.Errorf("field timeout: %w", err)
+				}
+				x.Timeout = v
+			}
+		case "manager":
+			if seen[28] {
+				return fmt.Errorf("field manager repeated in record")
+			}
+			seen[28] = true
+			if r.ReadNull() {
+				x.Manager = nil
+			} else {
+				x.Manager = new(User)
+				if err := x.Manager.readProto(r); err != nil {
+					return fmt.Errorf("field manager: %w", err)
+				}
+			}
+		default:
+			return fmt.Errorf("record field %q has no matching field in User", name)
+		}
+	}
+	r.EndRecord()
+	if !seen[0] {
+		return fmt.Errorf("field id missing from record")
+	}
+	if !seen[1] {
+		return fmt.Errorf("field name missing from record")
+	}
+	if !seen[2] {
+		return fmt.Errorf("field status missing from record")
+	}
+	if !seen[3] {
+		return fmt.Errorf("field age missing from record")
+	}
+	if !seen[4] {
+		return fmt.Errorf("field level missing from record")
+	}
+	if !seen[5] {
+		return fmt.Errorf("field count missing from record")
+	}
+	if !seen[7] {
+		return fmt.Errorf("field score missing from record")
+	}
+	if !seen[9] {
+		return fmt.Errorf("field admin missing from record")
+	}
+	if !seen[11] {
+		return fmt.Errorf("field avatar missing from record")
+	}
+	if !seen[13] {
+		return fmt.Errorf("field scores missing from record")
+	}
+	if !seen[17] {
+		return fmt.Errorf("field tags missing from record")
+	}
+	if !seen[19] {
+		return fmt.Errorf("field home missing from record")
+	}
+	if !seen[21] {
+		return fmt.Errorf("field previous missing from record")
+	}
+	if !seen[22] {
+		return fmt.Errorf("field backup missing from record")
+	}
+	if !seen[24] {
+		return fmt.Errorf("field nickname missing from record")
+	}
+	if !seen[26] {
+		return fmt.Errorf("field created missing from record")
+	}
+	if !seen[27] {
+		return fmt.Errorf("field timeout missing from record")
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package example
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/protocol/db-integration/protocol"
+)
+
+func testUser() *User {
+	nickname := "al"
+	return &User{
+		ID:       42,
+		Name:     "alice",
+		Status:   "active",
+		Age:      31,
+		Level:    -300,
+		Count:    1 << 40,
+		Quota:    7,
+		Score:    9.5,
+		Ratio:    float32(math.Inf(-1)),
+		Admin:    true,
+		Avatar:   []byte{1, 2, 3},
+		Key:      Blob("key"),
+		Scores:   []int32{1, -2, 1 << 30},
+		Totals:   []int64{1 << 40},
+		Weights:  []float64{0.5},
+		Flags:    []bool{true, false},
+		Tags:     []string{"a", "b"},
+		Labels:   Labels{"x"},
+		Home:     &Address{Street: "Main St", Zip: 12345},
+		Previous: []Address{{Street: "Old Rd"}, {Street: "Elm St", Zip: 1}},
+		Backup:   Address{Street: "PO Box"},
+		Profile:  Profile{Links: []string{}},
+		Nickname: &nickname,
+		Matrix:   [][]int16{{1, 2}, {}, {3}},
+		Created:  time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC),
+		Timeout:  5 * time.Second,
+		Manager:  &User{ID: 1, Name: "bob"},
+		Cache:    []byte("not sent"),
+	}
+}
+
+// TestMarshalProto tests that generated code writes what the reflection
+// path writes, and that the generic decoder reads it
+func TestMarshalProto(t *testing.T) {
+	// A profile with only unexported fields set is not empty
+	hidden := &User{Profile: Profile{visits: map[string]int{}}}
+	for _, in := range []*User{testUser(), {}, hidden} {
+		want, err := protocol.Marshal(in)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		got := in.MarshalProto(nil)
+		if !bytes.Equal(got, want) {
+			t.Errorf("MarshalProto = %x, want %x", got, want)
+		}
+		v, err := protocol.Decode(got)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if r, ok := v.(*protocol.Record); !ok || r.Name() != "User" {
+			t.Errorf("Decode = %v, want a User record", v)
+		}
+	}
+	v, _ := protocol.Decode(hidden.MarshalProto(nil))
+	if r, ok := v.(*protocol.Record); !ok {
+		t.Errorf("Decode = %v, want a User record", v)
+	} else if _, ok := r.Get("profile"); !ok {
+		t.Error("MarshalProto left out a profile with only unexported fields set")
+	}
+}
+
+// TestUnmarshalProto tests generated decoding against both encoders
+func TestUnmarshalProto(t *testing.T) {
+	in := testUser()
+	data := in.MarshalProto(nil)
+
+	var out User
+	if err := out.UnmarshalProto(data); err != nil {
+		t.Fatalf("UnmarshalProto failed: %v", err)
+	}
+	if got := out.MarshalProto(nil); !bytes.Equal(got, data) {
+		t.Errorf("round trip = %x, want %x", got, data)
+	}
+	if out.Home.Zip != 12345 || *out.Nickname != "al" || out.Manager.Name != "bob" || out.Cache != nil {
+		t.Errorf("UnmarshalProto = %+v", out)
+	}
+
+	var viaReflect User
+	if err := protocol.Unmarshal(data, &viaReflect); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(viaReflect, out) {
+		t.Errorf("Unmarshal = %+v, want %+v", viaReflect, out)
+	}
+
+	// Unmarshaling resets fields the message leaves out
+	if err := out.UnmarshalProto((&User{Name: "carol"}).MarshalProto(nil)); err != nil || out.Email != "" || out.Home != nil {
+		t.Errorf("UnmarshalProto kept old fields: %+v, %v", out, err)
+	}
+}
+
+// TestUnmarshalProtoErrors tests messages that do not fit the struct
+func TestUnmarshalProtoErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+		kind  error
+	}{
+		{"Unknown field", protocol.NewRecord("a", protocol.Field{Name: "street", Value: "s"}, protocol.Field{Name: "city", Value: "c"}), `record field "city" has no matching field`, nil},
+		{"Missing field", protocol.NewRecord("a", protocol.Field{Name: "zip", Value: int32(1)}), "field street missing", nil},
+		{"Wrong type", protocol.NewRecord("a", protocol.Field{Name: "street", Value: int32(1)}), "field street", protocol.ErrUnexpectedType},
+		{"Overflow", protocol.NewRecord("a", protocol.Field{Name: "street", Value: "s"}, protocol.Field{Name: "zip", Value: int64(1 << 40)}), "overflows int32", protocol.ErrInvalidValue},
+		{"Not a record", "s", "string where Record was expected", protocol.ErrUnexpectedType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := protocol.Encode(tt.value)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			var addr Address
+			err = addr.UnmarshalProto(data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("UnmarshalProto error = %v, want %q", err, tt.want)
+			}
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Errorf("UnmarshalProto error = %v, want %v", err, tt.kind)
+			}
+		})
+	}
+
+	// Records built with protocol.NewRecord cannot repeat a field
+	var names protocol.NameTable
+	repeated := protocol.AppendRecordHeader(nil, &names, "Address", 2)
+	for _, street := range []string{"a", "b"} {
+		repeated = protocol.AppendFieldName(repeated, &names, "street")
+		repeated = protocol.AppendString(repeated, street)
+	}
+	var addr Address
+	if err := addr.UnmarshalProto(repeated); err == nil || !strings.Contains(err.Error(), "field street repeated") {
+		t.Errorf("UnmarshalProto error = %v, want repeated field", err)
+	}
+
+	data := append((&Address{Street: "s"}).MarshalProto(nil), protocol.TypeNull)
+	if err := addr.UnmarshalProto(data); !errors.Is(err, protocol.ErrTrailingData) {
+		t.Errorf("UnmarshalProto error = %v, want %v", err, protocol.ErrTrailingData)
+	}
+	if err := addr.UnmarshalProto(data[:len(data)-3]); !errors.Is(err, protocol.ErrTruncated) {
+		t.Errorf("UnmarshalProto error = %v, want %v", err, protocol.ErrTruncated)
+	}
+}
+
+// TestMarshalProtoAllocs tests that marshaling into a large enough buffer
+// does not allocate
+func TestMarshalProtoAllocs(t *testing.T) {
+	in := testUser()
+	buf := make([]byte, 0, 1024)
+	if allocs := testing.AllocsPerRun(100, func() { in.MarshalProto(buf) }); allocs != 0 {
+		t.Errorf("MarshalProto allocated %v times, want 0", allocs)
+	}
+}
+
+func BenchmarkMarshalProto(b *testing.B) {
+	in := testUser()
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = in.MarshalProto(buf[:0])
+	}
+}
+
+func BenchmarkMarshalReflect(b *testing.B) {
+	in := testUser()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := protocol.Marshal(in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalProto(b *testing.B) {
+	data := testUser().MarshalProto(nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var out User
+		if err := out.UnmarshalProto(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// annotation marks the structs to generate methods for
+const annotation = "//protocol:generate"
+
+// kind is how a field type is encoded
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindUint
+	kindFloat32
+	kindFloat64
+	kindBytes
+	kindInt32Array
+	kindInt64Array
+	kindFloat64Array
+	kindBoolArray
+	kindTime
+	kindDuration
+	kindStruct
+	kindPointer
+	kindSlice
+)
+
+// fieldType describes the encoding of a Go type
+type fieldType struct {
+	kind   kind
+	bits   int        // size of kindInt and kindUint, 0 for int and uint
+	goType string     // Go type as written in the source
+	elem   *fieldType // element of kindPointer and kindSlice
+}
+
+// basicTypes are the predeclared types a field may have
+var basicTypes = map[string]fieldType{
+	"string":  {kind: kindString},
+	"bool":    {kind: kindBool},
+	"int8":    {kind: kindInt, bits: 8},
+	"int16":   {kind: kindInt, bits: 16},
+	"int32":   {kind: kindInt, bits: 32},
+	"int64":   {kind: kindInt, bits: 64},
+	"int":     {kind: kindInt},
+	"uint8":   {kind: kindUint, bits: 8},
+	"byte":    {kind: kindUint, bits: 8},
+	"uint16":  {kind: kindUint, bits: 16},
+	"uint32":  {kind: kindUint, bits: 32},
+	"uint64":  {kind: kindUint, bits: 64},
+	"uint":    {kind: kindUint},
+	"float32": {kind: kindFloat32},
+	"float64": {kind: kindFloat64},
+}
+
+// packedTypes are the element types of unnamed slices sent as packed arrays
+var packedTypes = map[string]kind{
+	"byte":    kindBytes,
+	"uint8":   kindBytes,
+	"int32":   kindInt32Array,
+	"int64":   kindInt64Array,
+	"float64": kindFloat64Array,
+	"bool":    kindBoolArray,
+}
+
+// field is one encoded struct field
+type field struct {
+	goName    string
+	name      string
+	omitEmpty bool
+	typ       *fieldType
+}
+
+// structInfo is an annotated struct
+type structInfo struct {
+	name   string
+	fields []field
+}
+
+// generator holds the declarations of one input file
+type generator struct {
+	named   map[string]ast.Expr        // non-struct type declarations, by name
+	structs map[string]*ast.StructType // struct declarations, by name
+	plain   map[string]bool            // structs declared without the annotation
+	uses    map[string]bool            // packages the generated code refers to
+	buf     bytes.Buffer
+}
+
+// generateFile returns the generated source for the annotated structs of a
+// file, or nil if it has none
+func generateFile(path string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{
+		named:   make(map[string]ast.Expr),
+		structs: make(map[string]*ast.StructType),
+		plain:   make(map[string]bool),
+		uses:    map[string]bool{"fmt": true},
+	}
+	var specs []*ast.TypeSpec
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, isStruct := ts.Type.(*ast.StructType)
+			if !isStruct {
+				g.named[ts.Name.Name] = ts.Type
+				continue
+			}
+			g.structs[ts.Name.Name] = st
+			if annotated(gen.Doc) || annotated(ts.Doc) {
+				specs = append(specs, ts)
+			} else {
+				g.plain[ts.Name.Name] = true
+			}
+		}
+	}
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	var structs []structInfo
+	for _, ts := range specs {
+		s, err := g.collectFields(ts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", fset.Position(ts.Pos()), ts.Name.Name, err)
+		}
+		structs = append(structs, s)
+	}
+	return g.generate(file.Name.Name, structs)
+}
+
+// annotated reports whether a doc comment holds the annotation line
+func annotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+// collectFields collects the encoded fields of a struct the way
+// protocol.Marshal does: exported fields, named by their proto tag
+func (g *generator) collectFields(ts *ast.TypeSpec) (structInfo, error) {
+	s := structInfo{name: ts.Name.Name}
+	seen := make(map[string]string)
+	for _, f := range ts.Type.(*ast.StructType).Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			unquoted, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return s, err
+			}
+			tag = reflect.StructTag(unquoted)
+		}
+		for _, goName := range fieldNames(f) {
+			if !ast.IsExported(goName) {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag.Get("proto"), ",")
+			if name == "-" && opts == "" {
+				continue
+			}
+			if name == "" {
+				name = goName
+			}
+			if other, ok := seen[name]; ok {
+				return s, fmt.Errorf("fields %s and %s are both named %q", other, goName, name)
+			}
+			seen[name] = goName
+
+			typ, err := g.resolve(f.Type, 0)
+			if err == nil && typ.kind == kindStruct && opts == "omitempty" {
+				_, err = g.zeroCheck(f.Type, "x."+goName, typ.goType, 0)
+			}
+			if err != nil {
+				return s, fmt.Errorf("field %s: %w", goName, err)
+			}
+			s.fields = append(s.fields, field{
+				goName:    goName,
+				name:      name,
+				omitEmpty: opts == "omitempty",
+				typ:       typ,
+			})
+		}
+	}
+	return s, nil
+}
+
+// fieldNames returns the Go names of the fields of a struct field list
+// entry
+func fieldNames(f *ast.Field) []string {
+	if len(f.Names) == 0 {
+		// Embedded fields are named after their type
+		typeName := strings.TrimPrefix(types.ExprString(f.Type), "*")
+		return []string{typeName[strings.LastIndex(typeName, ".")+1:]}
+	}
+	names := make([]string, 0, len(f.Names))
+	for _, n := range f.Names {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+// maxNamedDepth bounds the resolution of named types, which only recurse
+// through invalid declarations such as `type List []List`
+const maxNamedDepth = 32
+
+// resolve works out the encoding of a type expression
+func (g *generator) resolve(expr ast.Expr, depth int) (*fieldType, error) {
+	goType := types.ExprString(expr)
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if t, ok := basicTypes[e.Name]; ok {
+			t.goType = e.Name
+			return &t, nil
+		}
+		if under, ok := g.named[e.Name]; ok {
+			if depth >= maxNamedDepth {
+				return nil, fmt.Errorf("type %s does not resolve", e.Name)
+			}
+			return g.resolveNamed(e.Name, under, depth+1)
+		}
+		if g.plain[e.Name] {
+			return nil, fmt.Errorf("struct %s is not annotated with %s", e.Name, annotation)
+		}
+		// Any other name is taken to be an annotated struct of the package
+		return &fieldType{kind: kindStruct, goType: e.Name}, nil
+
+	case *ast.SelectorExpr:
+		switch goType {
+		case "time.Time":
+			return &fieldType{kind: kindTime, goType: goType}, nil
+		case "time.Duration":
+			return &fieldType{kind: kindDuration, goType: goType}, nil
+		}
+
+	case *ast.StarExpr:
+		elem, err := g.resolve(e.X, depth)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kindPointer, goType: goType, elem: elem}, nil
+
+	case *ast.ArrayType:
+		if e.Len != nil {
+			break
+		}
+		if id, ok := e.Elt.(*ast.Ident); ok {
+			if k, ok := packedTypes[id.Name]; ok {
+				return &fieldType{kind: k, goType: goType}, nil
+			}
+		}
+		elem, err := g.resolve(e.Elt, depth)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kindSlice, goType: goType, elem: elem}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", goType)
+}
+
+// resolveNamed resolves a named type declared in the file. Like
+// protocol.Marshal, only named byte slices keep a native encoding: other
+// named slices are sent as DataInputs and named durations as int64.
+func (g *generator) resolveNamed(name string, under ast.Expr, depth int) (*fieldType, error) {
+	var t *fieldType
+	if arr, ok := under.(*ast.ArrayType); ok && arr.Len == nil {
+		elem, err := g.resolve(arr.Elt, depth)
+		if err != nil {
+			return nil, err
+		}
+		if elem.kind == kindUint && elem.bits == 8 {
+			t = &fieldType{kind: kindBytes}
+		} else {
+			t = &fieldType{kind: kindSlice, elem: elem}
+		}
+	} else {
+		resolved, err := g.resolve(under, depth)
+		if err != nil {
+			return nil, err
+		}
+		copied := *resolved
+		t = &copied
+		switch t.kind {
+		case kindDuration:
+			t.kind, t.bits = kindInt, 64
+		case kindTime:
+			return nil, fmt.Errorf("unsupported type %s: named time.Time", name)
+		}
+	}
+	t.goType = name
+	return t, nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate writes the methods of all structs and formats the file
+func (g *generator) generate(pkg string, structs []structInfo) ([]byte, error) {
+	for _, s := range structs {
+		g.marshalMethods(s)
+		g.unmarshalMethods(s)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by protocol-gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	for _, imp := range []string{"fmt", "math", "strconv", "time"} {
+		if g.uses[imp] {
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+	}
+	out.WriteString("\n\t\"github.com/protocol/db-integration/protocol\"\n)\n")
+	out.Write(g.buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// marshalMethods writes MarshalProto and appendProto
+func (g *generator) marshalMethods(s structInfo) {
+	g.printf("\n// MarshalProto appends the encoding of x to dst, in the format of\n")
+	g.printf("// protocol.Marshal.\n")
+	g.printf("func (x *%s) MarshalProto(dst []byte) []byte {\n", s.name)
+	g.printf("var names protocol.NameTable\n")
+	g.printf("return x.appendProto(dst, &names)\n}\n\n")
+
+	g.printf("func (x *%s) appendProto(dst []byte, names *protocol.NameTable) []byte {\n", s.name)
+	required := 0
+	for _, f := range s.fields {
+		if !f.omitEmpty {
+			required++
+		}
+	}
+	g.printf("n := %d\n", required)
+	for _, f := range s.fields {
+		if f.omitEmpty {
+			g.printf("if %s {\nn++\n}\n", g.nonZero(f.typ, "x."+f.goName))
+		}
+	}
+	g.printf("dst = protocol.AppendRecordHeader(dst, names, %q, n)\n", s.name)
+	for _, f := range s.fields {
+		if f.omitEmpty {
+			g.printf("if %s {\n", g.nonZero(f.typ, "x."+f.goName))
+		}
+		g.printf("dst = protocol.AppendFieldName(dst, names, %q)\n", f.name)
+		g.appendValue(f.typ, "x."+f.goName, 0)
+		if f.omitEmpty {
+			g.printf("}\n")
+		}
+	}
+	g.printf("return dst\n}\n")
+}
+
+// typeName returns the Go type of t for use in the generated code
+func (g *generator) typeName(t *fieldType) string {
+	// Type expressions hold no literals, so this only matches the package
+	if strings.Contains(t.goType, "time.") {
+		g.uses["time"] = true
+	}
+	return t.goType
+}
+
+// nonZero returns a condition that holds when expr is not the zero value,
+// matching reflect.Value.IsZero
+func (g *generator) nonZero(t *fieldType, expr string) string {
+	switch t.kind {
+	case kindFloat32, kindFloat64, kindTime:
+		g.uses[map[kind]string{kindFloat32: "math", kindFloat64: "math", kindTime: "time"}[t.kind]] = true
+	}
+	switch t.kind {
+	case kindString:
+		return expr + ` != ""`
+	case kindBool:
+		return expr
+	case kindInt, kindUint, kindDuration:
+		return expr + " != 0"
+	case kindFloat32:
+		return "math.Float32bits(" + convert(t, "float32", expr) + ") != 0"
+	case kindFloat64:
+		return "math.Float64bits(" + convert(t, "float64", expr) + ") != 0"
+	case kindTime:
+		return expr + " != (time.Time{})"
+	case kindStruct:
+		// collectFields has checked that the struct can be tested
+		cond, _ := g.zeroCheck(ast.NewIdent(t.goType), expr, t.goType, 0)
+		return cond
+	default:
+		return expr + " != nil"
+	}
+}
+
+// zeroCheck returns a condition that holds when x, of the type expr written
+// goType, is not the zero value. Structs are tested field by field, as
+// reflect.Value.IsZero does, since those holding slices, maps or funcs
+// cannot be compared with a literal; only types declared in the file can be
+// looked into.
+func (g *generator) zeroCheck(expr ast.Expr, x, goType string, depth int) (string, error) {
+	if depth >= maxNamedDepth {
+		return "", fmt.Errorf("type %s does not resolve", goType)
+	}
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if t, ok := basicTypes[e.Name]; ok {
+			t.goType = goType
+			return g.nonZero(&t, x), nil
+		}
+		if under, ok := g.named[e.Name]; ok {
+			return g.zeroCheck(under, x, goType, depth+1)
+		}
+		if st, ok := g.structs[e.Name]; ok {
+			return g.structZeroCheck(st, x, depth+1)
+		}
+		if e.Name == "error" || e.Name == "any" {
+			return x + " != nil", nil
+		}
+		return "", fmt.Errorf("omitempty needs the fields of %s, which is not declared in this file", e.Name)
+
+	case *ast.SelectorExpr:
+		switch types.ExprString(e) {
+		case "time.Time":
+			t := &fieldType{kind: kindTime, goType: goType}
+			return g.nonZero(t, convert(t, "time.Time", x)), nil
+		case "time.Duration":
+			return x + " != 0", nil
+		}
+
+	case *ast.StructType:
+		return g.structZeroCheck(e, x, depth+1)
+
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return x + " != nil", nil
+		}
+
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return x + " != nil", nil
+	}
+	return "", fmt.Errorf("omitempty cannot test %s of type %s", x, types.ExprString(expr))
+}
+
+// structZeroCheck returns a condition that holds when any field of the
+// struct x is not the zero value
+func (g *generator) structZeroCheck(st *ast.StructType, x string, depth int) (string, error) {
+	var conds []string
+	for _, f := range st.Fields.List {
+		for _, name := range fieldNames(f) {
+			if name == "_" {
+				continue
+			}
+			cond, err := g.zeroCheck(f.Type, x+"."+name, types.ExprString(f.Type), depth)
+			if err != nil {
+				return "", err
+			}
+			conds = append(conds, cond)
+		}
+	}
+	switch len(conds) {
+	case 0:
+		return "false", nil
+	case 1:
+		return conds[0], nil
+	}
+	return "(" + strings.Join(conds, " || ") + ")", nil
+}
+
+// operand wraps a dereference so that it can be indexed or called
+func operand(expr string) string {
+	if strings.HasPrefix(expr, "*") {
+		return "(" + expr + ")"
+	}
+	return expr
+}
+
+// convert returns expr converted to base unless t already is that type
+func convert(t *fieldType, base, expr string) string {
+	if t.goType == base {
+		return expr
+	}
+	return base + "(" + expr + ")"
+}
+
+// appendValue writes the statements appending expr
+func (g *generator) appendValue(t *fieldType, expr string, depth int) {
+	switch t.kind {
+	case kindString:
+		g.printf("dst = protocol.AppendString(dst, %s)\n", convert(t, "string", expr))
+	case kindBool:
+		g.printf("dst = protocol.AppendBool(dst, %s)\n", convert(t, "bool", expr))
+	case kindInt:
+		if t.bits != 0 && t.bits <= 32 {
+			g.printf("dst = protocol.AppendInt32(dst, %s)\n", convert(t, "int32", expr))
+		} else {
+			g.printf("dst = protocol.AppendInt64(dst, %s)\n", convert(t, "int64", expr))
+		}
+	case kindUint:
+		g.printf("dst = protocol.AppendUint64(dst, %s)\n", convert(t, "uint64", expr))
+	case kindFloat32:
+		g.printf("dst = protocol.AppendFloat32(dst, %s)\n", convert(t, "float32", expr))
+	case kindFloat64:
+		g.printf("dst = protocol.AppendFloat64(dst, %s)\n", convert(t, "float64", expr))
+	case kindBytes:
+		g.printf("dst = protocol.AppendBytes(dst, %s)\n", convert(t, "[]byte", expr))
+	case kindInt32Array:
+		g.printf("dst = protocol.AppendInt32Array(dst, %s)\n", expr)
+	case kindInt64Array:
+		g.printf("dst = protocol.AppendInt64Array(dst, %s)\n", expr)
+	case kindFloat64Array:
+		g.printf("dst = protocol.AppendFloat64Array(dst, %s)\n", expr)
+	case kindBoolArray:
+		g.printf("dst = protocol.AppendBoolArray(dst, %s)\n", expr)
+	case kindTime:
+		g.printf("dst = protocol.AppendTime(dst, %s)\n", expr)
+	case kindDuration:
+		g.printf("dst = protocol.AppendDuration(dst, %s)\n", expr)
+	case kindStruct:
+		g.printf("dst = %s.appendProto(dst, names)\n", operand(expr))
+	case kindPointer:
+		g.printf("if %s == nil {\ndst = protocol.AppendNull(dst)\n} else {\n", expr)
+		if t.elem.kind == kindStruct {
+			g.printf("dst = %s.appendProto(dst, names)\n", operand(expr))
+		} else {
+			g.appendValue(t.elem, "*"+expr, depth)
+		}
+		g.printf("}\n")
+	case kindSlice:
+		i := fmt.Sprintf("i%d", depth)
+		g.printf("if %s == nil {\ndst = protocol.AppendNull(dst)\n} else {\n", expr)
+		g.printf("dst = protocol.AppendListHeader(dst, len(%s))\n", expr)
+		g.printf("for %s := range %s {\n", i, expr)
+		g.appendValue(t.elem, operand(expr)+"["+i+"]", depth+1)
+		g.printf("}\n}\n")
+	}
+}
+
+// unmarshalMethods writes UnmarshalProto and readProto
+func (g *generator) unmarshalMethods(s structInfo) {
+	g.printf("\n// UnmarshalProto decodes a Record written by MarshalProto or\n")
+	g.printf("// protocol.Marshal into x.\n")
+	g.printf("func (x *%s) UnmarshalProto(data []byte) error {\n", s.name)
+	g.printf("r := protocol.NewReader(data)\n")
+	g.printf("if err := x.readProto(r); err != nil {\n")
+	g.printf("return fmt.Errorf(\"protocol: unmarshal %s: %%w\", err)\n}\n", s.name)
+	g.printf("return r.Done()\n}\n\n")
+
+	g.printf("func (x *%s) readProto(r *protocol.Reader) error {\n", s.name)
+	g.printf("*x = %s{}\n", s.name)
+	g.printf("if r.ReadNull() {\nreturn nil\n}\n")
+	g.printf("n, err := r.ReadRecordHeader()\nif err != nil {\nreturn err\n}\n")
+	if len(s.fields) > 0 {
+		g.printf("var seen [%d]bool\n", len(s.fields))
+	}
+	g.printf("for i := 0; i < n; i++ {\n")
+	g.printf("name, err := r.ReadFieldName()\nif err != nil {\nreturn err\n}\n")
+	g.printf("switch name {\n")
+	for i, f := range s.fields {
+		g.printf("case %q:\n", f.name)
+		g.printf("if seen[%d] {\nreturn fmt.Errorf(\"field %s repeated in record\")\n}\n", i, f.name)
+		g.printf("seen[%d] = true\n", i)
+		g.readValue(f.typ, "x."+f.goName, fmt.Sprintf("%q", "field "+f.name+": %w"), 0)
+	}
+	g.printf("default:\n")
+	g.printf("return fmt.Errorf(\"record field %%q has no matching field in %s\", name)\n", s.name)
+	g.printf("}\n}\n")
+	g.printf("r.EndRecord()\n")
+	for i, f := range s.fields {
+		if !f.omitEmpty {
+			g.printf("if !seen[%d] {\nreturn fmt.Errorf(\"field %s missing from record\")\n}\n", i, f.name)
+		}
+	}
+	g.printf("return nil\n}\n")
+}
+
+// readMethods names the Reader method and result type for scalar kinds
+var readMethods = map[kind][2]string{
+	kindString:       {"ReadString()", "string"},
+	kindBool:         {"ReadBool()", "bool"},
+	kindFloat32:      {"ReadFloat32()", "float32"},
+	kindFloat64:      {"ReadFloat64()", "float64"},
+	kindBytes:        {"ReadBytes()", "[]byte"},
+	kindInt32Array:   {"ReadInt32Array()", "[]int32"},
+	kindInt64Array:   {"ReadInt64Array()", "[]int64"},
+	kindFloat64Array: {"ReadFloat64Array()", "[]float64"},
+	kindBoolArray:    {"ReadBoolArray()", "[]bool"},
+	kindTime:         {"ReadTime()", "time.Time"},
+	kindDuration:     {"ReadDuration()", "time.Duration"},
+}
+
+// readValue writes the statements reading into the addressable expression
+// dst; wrap is the fmt.Errorf format applied to errors
+func (g *generator) readValue(t *fieldType, dst, wrap string, depth int) {
+	switch t.kind {
+	case kindInt, kindUint:
+		method, base, bits := "ReadInt", "int64", strconv.Itoa(t.bits)
+		if t.kind == kindUint {
+			method, base = "ReadUint", "uint64"
+		}
+		if t.bits == 0 {
+			bits = "strconv.IntSize"
+			g.uses["strconv"] = true
+		}
+		g.printf("{\nv, err := r.%s(%s)\nif err != nil {\nreturn fmt.Errorf(%s, err)\n}\n", method, bits, wrap)
+		g.printf("%s = %s\n}\n", dst, convert(&fieldType{goType: base}, t.goType, "v"))
+	case kindStruct:
+		g.printf("if err := %s.readProto(r); err != nil {\nreturn fmt.Errorf(%s, err)\n}\n", operand(dst), wrap)
+	case kindPointer:
+		g.printf("if r.ReadNull() {\n%s = nil\n} else {\n", dst)
+		g.printf("%s = new(%s)\n", dst, g.typeName(t.elem))
+		if t.elem.kind == kindStruct {
+			g.printf("if err := %s.readProto(r); err != nil {\nreturn fmt.Errorf(%s, err)\n}\n", operand(dst), wrap)
+		} else {
+			g.readValue(t.elem, "*"+dst, wrap, depth)
+		}
+		g.printf("}\n")
+	case kindSlice:
+		i, count := fmt.Sprintf("i%d", depth), fmt.Sprintf("n%d", depth)
+		g.printf("if r.ReadNull() {\n%s = nil\n} else {\n", dst)
+		g.printf("%s, err := r.ReadListHeader()\nif err != nil {\nreturn fmt.Errorf(%s, err)\n}\n", count, wrap)
+		g.printf("%s = make(%s, %s)\n", dst, g.typeName(t), count)
+		g.printf("for %s := range %s {\n", i, dst)
+		g.readValue(t.elem, operand(dst)+"["+i+"]", wrap, depth+1)
+		g.printf("}\nr.EndList()\n}\n")
+	default:
+		m := readMethods[t.kind]
+		g.printf("{\nv, err := r.%s\nif err != nil {\nreturn fmt.Errorf(%s, err)\n}\n", m[0], wrap)
+		g.printf("%s = %s\n}\n", dst, convert(&fieldType{goType: m[1]}, t.goType, "v"))
+	}
+}
@@ -0,0 +1,65 @@
+// Command protocol-gen generates MarshalProto and UnmarshalProto methods for
+// Go structs, writing the same Records as protocol.Marshal without
+// reflection. MarshalProto does not allocate beyond growing its buffer.
+//
+// Annotate each struct with a //protocol:generate comment and add a
+// go:generate directive to the file:
+//
+//	//go:generate go run github.com/protocol/db-integration/cmd/protocol-gen $GOFILE
+//
+//	//protocol:generate
+//	type User struct {
+//		ID    int64  `proto:"id"`
+//		Email string `proto:"email,omitempty"`
+//	}
+//
+// For each input file.go the methods are written to file_proto.go. Struct
+// fields may be strings, bools, integers, floats, []byte, packed []int32,
+// []int64, []float64 and []bool, time.Time, time.Duration, other annotated
+// structs of the package, and pointers to and slices of these. Named types
+// declared in the same file are resolved to their underlying type. An
+// omitempty struct field is tested field by field, so its type must be
+// declared in the same file.
+// UnmarshalProto reads Records only, not positional DataInputs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("protocol-gen: ")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: protocol-gen file.go...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	for _, path := range flag.Args() {
+		src, err := generateFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if src == nil {
+			log.Printf("%s: no //protocol:generate structs", path)
+			continue
+		}
+		if err := os.WriteFile(outputPath(path), src, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// outputPath returns the name of the generated file for an input file
+func outputPath(path string) string {
+	return strings.TrimSuffix(path, ".go") + "_proto.go"
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGolden tests that the checked-in example output is up to date
+func TestGolden(t *testing.T) {
+	got, err := generateFile("example/types.go")
+	if err != nil {
+		t.Fatalf("generateFile failed: %v", err)
+	}
+	want, err := os.ReadFile("example/types_proto.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("example/types_proto.go is stale; run go generate ./cmd/protocol-gen/example")
+	}
+}
+
+// TestGenerateErrors tests structs the generator rejects
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Map field", "type T struct { M map[string]int }", "field M: unsupported type map[string]int"},
+		{"Unannotated struct", "type U struct{}\n//protocol:generate\ntype T struct { U U }", "field U: struct U is not annotated"},
+		{"Duplicate name", "type T struct {\nA int `proto:\"x\"`\nB int `proto:\"x\"`\n}", `fields A and B are both named "x"`},
+		{"Named time", "type When time.Time\n//protocol:generate\ntype T struct { W When }", "named time.Time"},
+		{"Omitempty struct elsewhere", "type T struct { U Other `proto:\",omitempty\"` }", "Other, which is not declared in this file"},
+		{"Omitempty array", "//protocol:generate\ntype U struct { a [2]int }\n//protocol:generate\ntype T struct { U U `proto:\",omitempty\"` }", "cannot test x.U.a of type [2]int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package p\n\nimport \"time\"\n\nvar _ time.Time\n\n"
+			if !strings.Contains(tt.src, "//protocol:generate") {
+				src += "//protocol:generate\n"
+			}
+			path := filepath.Join(t.TempDir(), "p.go")
+			if err := os.WriteFile(path, []byte(src+tt.src+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := generateFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("generateFile error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestGenerateOmitEmptyStruct tests that an omitempty struct is tested field
+// by field, as one holding a slice or func cannot be compared with a literal
+func TestGenerateOmitEmptyStruct(t *testing.T) {
+	src := `package p
+
+import "time"
+
+type Ratio float32
+
+type inner struct {
+	F func()
+	R Ratio
+}
+
+//protocol:generate
+type U struct {
+	Tags []string
+	At   time.Time
+	in   inner
+	_    int
+}
+
+//protocol:generate
+type T struct {
+	U U ` + "`proto:\"u,omitempty\"`" + `
+}
+`
+	path := filepath.Join(t.TempDir(), "p.go")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := generateFile(path)
+	if err != nil {
+		t.Fatalf("generateFile failed: %v", err)
+	}
+	want := "if x.U.Tags != nil || x.U.At != (time.Time{}) || (x.U.in.F != nil || math.Float32bits(float32(x.U.in.R)) != 0) {"
+	if !strings.Contains(string(got), want) {
+		t.Errorf("generated code lacks %q:\n%s", want, got)
+	}
+}
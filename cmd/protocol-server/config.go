@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/protocol/db-integration/protocol"
+)
+
+// defaultConfigPath is where k8s-deployment.yaml mounts the protocol-config
+// ConfigMap.
+const defaultConfigPath = "/etc/protocol/protocol.yaml"
+
+// loadDecoderOptions returns the decoder limits for the server, taking
+// encoding.max_message_size from the config file when present. A missing
+// file leaves the defaults in place. Only the block structure of the YAML
+// is followed: the key counts only in the top-level encoding: block.
+func loadDecoderOptions(path string) (protocol.DecoderOptions, error) {
+	opts := protocol.DefaultDecoderOptions()
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return opts, nil
+	}
+	if err != nil {
+		return opts, err
+	}
+	defer f.Close()
+
+	// sections holds the keys of the blocks enclosing the current line, with
+	// their indentation, so that only the key under encoding: is read
+	type section struct {
+		indent int
+		key    string
+	}
+	var sections []section
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		text := strings.TrimSpace(line)
+		if text == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		for len(sections) > 0 && sections[len(sections)-1].indent >= indent {
+			sections = sections[:len(sections)-1]
+		}
+		key, value, ok := strings.Cut(text, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if value == "" {
+			sections = append(sections, section{indent, key})
+			continue
+		}
+		if key != "max_message_size" || len(sections) != 1 || sections[0].key != "encoding" {
+			continue
+		}
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return opts, fmt.Errorf("%s: invalid max_message_size %q", path, value)
+		}
+		opts.MaxMessageBytes = size
+		if opts.MaxStringBytes > size {
+			opts.MaxStringBytes = size
+		}
+	}
+	return opts, scanner.Err()
+}
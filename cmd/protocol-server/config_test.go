@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/protocol/db-integration/protocol"
+)
+
+// TestLoadDecoderOptions tests that max_message_size is read only from the
+// encoding: block
+func TestLoadDecoderOptions(t *testing.T) {
+	defaults := protocol.DefaultDecoderOptions().MaxMessageBytes
+	tests := []struct {
+		name string
+		yaml string
+		want int
+	}{
+		{"Encoding block", "server:\n  port: 8080\nencoding:\n  max_message_size: 1024  # bytes\n  compression_enabled: false\n", 1024},
+		{"Other section", "cache:\n  max_message_size: 1024\nencoding:\n  compression_enabled: false\n", defaults},
+		{"After encoding block", "encoding:\n  compression_enabled: false\nqueue:\n  max_message_size: 1024\n", defaults},
+		{"Top level", "max_message_size: 1024\n", defaults},
+		{"Nested deeper", "encoding:\n  limits:\n    max_message_size: 1024\n", defaults},
+		{"Commented out", "encoding:\n  # max_message_size: 1024\n", defaults},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "protocol.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			opts, err := loadDecoderOptions(path)
+			if err != nil {
+				t.Fatalf("loadDecoderOptions failed: %v", err)
+			}
+			if opts.MaxMessageBytes != tt.want {
+				t.Errorf("MaxMessageBytes = %d, want %d", opts.MaxMessageBytes, tt.want)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "protocol.yaml")
+	if err := os.WriteFile(path, []byte("encoding:\n  max_message_size: lots\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadDecoderOptions(path); err == nil {
+		t.Error("loadDecoderOptions accepted an invalid size")
+	}
+	if opts, err := loadDecoderOptions(filepath.Join(t.TempDir(), "missing.yaml")); err != nil || opts.MaxMessageBytes != defaults {
+		t.Errorf("missing file = %d, %v; want the defaults", opts.MaxMessageBytes, err)
+	}
+}
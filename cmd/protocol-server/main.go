@@ -0,0 +1,236 @@
+// Command protocol-server runs the protocol test cases, benchmarks and
+// extensibility notes against the protocol package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/protocol/db-integration/protocol"
+)
+
+// decoderOptions holds the limits applied to every decode, loaded from the
+// protocol config at startup
+var decoderOptions = protocol.DefaultDecoderOptions()
+
+func main() {
+	configPath := flag.String("config", defaultConfigPath, "path to protocol.yaml")
+	healthCheck := flag.Bool("health-check", false, "exit immediately with success (container health check)")
+	flag.Parse()
+	if *healthCheck {
+		return
+	}
+
+	opts, err := loadDecoderOptions(*configPath)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	decoderOptions = opts
+
+	fmt.Println("===========================================")
+	fmt.Println("Binary Protocol Implementation")
+	fmt.Println("===========================================")
+	fmt.Println()
+
+	runTests()
+	runBenchmarks()
+	demonstrateExtensibility()
+}
+
+func runTests() {
+	fmt.Println("Running Test Cases:")
+	fmt.Println("------------------")
+
+	fmt.Println("\nTest 1: Basic nested structure")
+	originalData := protocol.NewDataInput("foo", protocol.NewDataInput("bar", int32(42)))
+	encoded := mustEncode(originalData)
+	decoded := mustDecode(encoded)
+	
+	fmt.Printf("Original: %+v\n", protocol.Format(originalData))
+	fmt.Printf("Encoded size: %d bytes\n", len(encoded))
+	fmt.Printf("Decoded: %+v\n", protocol.Format(decoded))
+	fmt.Printf("Match: %v\n", protocol.Equal(originalData, decoded))
+
+	fmt.Println("\nTest 2: Complex nested structure")
+	complexData := protocol.NewDataInput(
+		"user_metrics",
+		int32(1234567),
+		protocol.NewDataInput(
+			"events",
+			int32(42),
+			"click",
+			protocol.NewDataInput("nested", int32(-999), "deep"),
+			"timestamp",
+		),
+		"end",
+	)
+	encoded2 := mustEncode(complexData)
+	decoded2 := mustDecode(encoded2)
+	fmt.Printf("Encoded size: %d bytes\n", len(encoded2))
+	fmt.Printf("Match: %v\n", protocol.Equal(complexData, decoded2))
+
+	fmt.Println("\nTest 3: Large string handling")
+	largeString := make([]byte, 100000)
+	for i := range largeString {
+		largeString[i] = byte('A' + (i % 26))
+	}
+	largeData := protocol.NewDataInput(string(largeString), int32(999))
+	encoded3 := mustEncode(largeData)
+	decoded3 := mustDecode(encoded3)
+	fmt.Printf("Large string size: %d bytes\n", len(largeString))
+	fmt.Printf("Encoded size: %d bytes\n", len(encoded3))
+	fmt.Printf("Match: %v\n", protocol.Equal(largeData, decoded3))
+
+	fmt.Println("\nTest 4: UTF-8 string support")
+	utf8Data := protocol.NewDataInput("Hello Ekansh", "🚀 Rocket", int32(2025), "abcdefg")
+	encoded4 := mustEncode(utf8Data)
+	decoded4 := mustDecode(encoded4)
+	fmt.Printf("Original: %+v\n", protocol.Format(utf8Data))
+	fmt.Printf("Decoded: %+v\n", protocol.Format(decoded4))
+	fmt.Printf("Match: %v\n", protocol.Equal(utf8Data, decoded4))
+
+	fmt.Println("\nTest 5: Edge cases")
+	edgeData := protocol.NewDataInput("", int32(0), protocol.NewDataInput(), int32(-2147483648))
+	encoded5 := mustEncode(edgeData)
+	decoded5 := mustDecode(encoded5)
+	fmt.Printf("Match: %v\n", protocol.Equal(edgeData, decoded5))
+}
+
+func runBenchmarks() {
+	fmt.Println("\n\nPerformance Benchmarks:")
+	fmt.Println("----------------------")
+
+	fmt.Println("\nBenchmark 1: Small messages (10 elements)")
+	smallData := protocol.NewDataInput()
+	for i := 0; i < 10; i++ {
+		smallData.Append(fmt.Sprintf("field_%d", i), int32(i))
+	}
+	benchmarkEncodeDecode(smallData, 10000)
+
+	fmt.Println("\nBenchmark 2: Medium messages (100 elements)")
+	mediumData := protocol.NewDataInput()
+	for i := 0; i < 100; i++ {
+		mediumData.Append(fmt.Sprintf("field_%d", i), int32(i))
+	}
+	benchmarkEncodeDecode(mediumData, 1000)
+
+	fmt.Println("\nBenchmark 3: Large nested structure")
+	nestedData := protocol.NewDataInput()
+	for i := 0; i < 10; i++ {
+		innerData := protocol.NewDataInput()
+		for j := 0; j < 10; j++ {
+			innerData.Append(fmt.Sprintf("data_%d_%d", i, j), int32(i*10+j))
+		}
+		nestedData.Append(innerData)
+	}
+	benchmarkEncodeDecode(nestedData, 1000)
+
+	fmt.Println("\nBenchmark 4: Maximum size array (1000 elements)")
+	maxData := protocol.NewDataInput()
+	for i := 0; i < 1000; i++ {
+		if i%3 == 0 {
+			maxData.Append(fmt.Sprintf("element_%d", i))
+		} else {
+			maxData.Append(int32(i))
+		}
+	}
+	benchmarkEncodeDecode(maxData, 100)
+}
+
+func benchmarkEncodeDecode(data *protocol.DataInput, iterations int) {
+	start := time.Now()
+	var encoded []byte
+	for i := 0; i < iterations; i++ {
+		encoded = mustEncode(data)
+	}
+	encodeTime := time.Since(start)
+
+	start = time.Now()
+	for i := 0; i < iterations; i++ {
+		_ = mustDecode(encoded)
+	}
+	decodeTime := time.Since(start)
+
+	fmt.Printf("Elements: %d, Encoded size: %d bytes\n", data.Len(), len(encoded))
+	fmt.Printf("Encode: %d iterations in %v (%.2f µs/op)\n", 
+		iterations, encodeTime, float64(encodeTime.Microseconds())/float64(iterations))
+	fmt.Printf("Decode: %d iterations in %v (%.2f µs/op)\n",
+		iterations, decodeTime, float64(decodeTime.Microseconds())/float64(iterations))
+
+	totalBytes := len(encoded) * iterations
+	encodeThroughput := float64(totalBytes) / encodeTime.Seconds() / 1024 / 1024
+	decodeThroughput := float64(totalBytes) / decodeTime.Seconds() / 1024 / 1024
+	fmt.Printf("Throughput - Encode: %.2f MB/s, Decode: %.2f MB/s\n", 
+		encodeThroughput, decodeThroughput)
+}
+
+func demonstrateExtensibility() {
+	fmt.Println("\n\nExtensibility Demonstration:")
+	fmt.Println("---------------------------")
+
+	fmt.Print(`
+Built-in types (tag):
+   null (0x00), string (0x01), int32 (0x02), DataInput (0x03),
+   float64 (0x04), float32 (0x05), int64 (0x06), uint64 (0x07),
+   bool (0x08/0x09), []byte (0x0A), time.Time (0x0B/0x0C),
+   time.Duration (0x0D), Map (0x0E), UUID (0x0F), Decimal (0x10),
+   *big.Int (0x11), TypedNull (0x12), packed []int32 (0x13),
+   []int64 (0x14), []float64 (0x15), []bool (0x16),
+   compact int32 (0x17 varint, 0x60-0xEF fixint), Record (0x18),
+   Enum (0x19), netip.Addr (0x1A), netip.Prefix (0x1B)
+
+To add an application-defined type without touching the codec:
+
+1. Implement protocol.TypeCodec for it:
+   type pointCodec struct{}
+   func (pointCodec) Type() reflect.Type { return reflect.TypeOf(Point{}) }
+   func (pointCodec) Tag() byte          { return 0xF0 }
+   func (pointCodec) Encode(dst []byte, v interface{}) ([]byte, error) {
+       p := v.(Point)
+       dst = binary.LittleEndian.AppendUint64(dst, math.Float64bits(p.X))
+       return binary.LittleEndian.AppendUint64(dst, math.Float64bits(p.Y)), nil
+   }
+   func (pointCodec) Decode(payload []byte) (interface{}, error) { ... }
+   func (pointCodec) Equal(a, b interface{}) bool { ... }
+   func (pointCodec) Format(v interface{}) string { ... }
+
+2. Register it, either globally or in a private registry:
+   protocol.Register(pointCodec{})
+   reg := protocol.NewRegistry(); reg.Register(pointCodec{})
+   protocol.EncodeWithOptions(v, protocol.EncoderOptions{Registry: reg})
+
+3. On the wire every extension is [0xF0-0xFF][Length][Payload]:
+   - Tags 0xF0-0xFF are reserved for application-defined types
+   - Decoders without the codec skip the element and return a
+     protocol.RawExtension, which re-encodes to the same bytes
+`)
+
+	fmt.Println("\nProtocol Efficiency Analysis:")
+	fmt.Println("- Variable-length encoding saves 1-7 bytes per integer")
+	fmt.Println("- Type tags use only 1 byte overhead per value")
+	fmt.Println("- No padding or alignment requirements")
+	fmt.Println("- Zero-copy decoding possible for primitive types")
+	fmt.Println("- Compression-friendly due to type clustering")
+}
+
+func mustEncode(v interface{}) []byte {
+	encoded, err := protocol.Encode(v)
+	if err != nil {
+		log.Fatalf("encode: %v", err)
+	}
+	return encoded
+}
+
+func mustDecode(encoded []byte) interface{} {
+	decoded, err := protocol.DecodeWithOptions(encoded, decoderOptions)
+	if err != nil {
+		log.Fatalf("decode: %v", err)
+	}
+	return decoded
+}
+
+func init() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+}
@@ -0,0 +1,159 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// The Append functions write a single element in the same format as
+// Encode, appending it to dst and returning the extended slice. They do not
+// allocate beyond growing dst, and are what code generated by
+// cmd/protocol-gen is built from.
+
+// AppendNull appends a null.
+func AppendNull(dst []byte) []byte {
+	return append(dst, TypeNull)
+}
+
+// AppendString appends [TypeString][Length as varint][UTF-8 bytes].
+func AppendString(dst []byte, s string) []byte {
+	dst = append(dst, TypeString)
+	dst = binary.AppendUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// AppendBytes appends [TypeBytes][Length as varint][raw bytes].
+func AppendBytes(dst []byte, b []byte) []byte {
+	dst = append(dst, TypeBytes)
+	dst = binary.AppendUvarint(dst, uint64(len(b)))
+	return append(dst, b...)
+}
+
+// AppendBool appends TypeTrue or TypeFalse.
+func AppendBool(dst []byte, b bool) []byte {
+	if b {
+		return append(dst, TypeTrue)
+	}
+	return append(dst, TypeFalse)
+}
+
+// AppendInt32 appends an int32 in its smallest form: a fixint tag for
+// -16..127, a zigzag varint when it takes at most 3 bytes, and otherwise
+// [TypeInt32][4 bytes little-endian].
+func AppendInt32(dst []byte, v int32) []byte {
+	if v >= fixIntMin && v <= fixIntMax {
+		return append(dst, TypeFixIntMin+byte(v-fixIntMin))
+	}
+	if z := zigzagEncode(int64(v)); z < 1<<21 {
+		dst = append(dst, TypeVarInt32)
+		return binary.AppendUvarint(dst, z)
+	}
+	dst = append(dst, TypeInt32)
+	return binary.LittleEndian.AppendUint32(dst, uint32(v))
+}
+
+// AppendInt64 appends [TypeInt64][zigzag varint].
+func AppendInt64(dst []byte, v int64) []byte {
+	dst = append(dst, TypeInt64)
+	return binary.AppendUvarint(dst, zigzagEncode(v))
+}
+
+// AppendUint64 appends [TypeUint64][varint].
+func AppendUint64(dst []byte, v uint64) []byte {
+	dst = append(dst, TypeUint64)
+	return binary.AppendUvarint(dst, v)
+}
+
+// AppendFloat64 appends [TypeFloat64][8 bytes IEEE 754 little-endian]. The
+// raw bits are written so NaN payloads, ±Inf and -0 survive.
+func AppendFloat64(dst []byte, f float64) []byte {
+	dst = append(dst, TypeFloat64)
+	return binary.LittleEndian.AppendUint64(dst, math.Float64bits(f))
+}
+
+// AppendFloat32 appends [TypeFloat32][4 bytes IEEE 754 little-endian].
+func AppendFloat32(dst []byte, f float32) []byte {
+	dst = append(dst, TypeFloat32)
+	return binary.LittleEndian.AppendUint32(dst, math.Float32bits(f))
+}
+
+// AppendTime appends [TypeTime][seconds][nanos], followed by the zone
+// offset in minutes for non-UTC times. Seconds rather than Unix nanoseconds
+// keep times outside 1678-2262 representable.
+func AppendTime(dst []byte, t time.Time) []byte {
+	utc := t.Location() == time.UTC
+	if utc {
+		dst = append(dst, TypeTime)
+	} else {
+		dst = append(dst, TypeTimeTZ)
+	}
+	dst = binary.AppendUvarint(dst, zigzagEncode(t.Unix()))
+	dst = binary.AppendUvarint(dst, uint64(t.Nanosecond()))
+	if !utc {
+		_, zoneOffset := t.Zone()
+		dst = binary.AppendUvarint(dst, zigzagEncode(int64(zoneOffset/60)))
+	}
+	return dst
+}
+
+// AppendDuration appends [TypeDuration][nanoseconds as zigzag varint].
+func AppendDuration(dst []byte, d time.Duration) []byte {
+	dst = append(dst, TypeDuration)
+	return binary.AppendUvarint(dst, zigzagEncode(int64(d)))
+}
+
+// AppendListHeader appends the header of a DataInput with count elements,
+// which must follow it.
+func AppendListHeader(dst []byte, count int) []byte {
+	dst = append(dst, TypeDataInput)
+	return binary.AppendUvarint(dst, uint64(count))
+}
+
+// NameTable interns the record and field names of one message written with
+// AppendRecordHeader and AppendFieldName. The zero value is an empty table;
+// use a new one for every message. Tables of up to 64 names do not
+// allocate.
+type NameTable struct {
+	small [64]string
+	n     int
+	more  map[string]int
+}
+
+// AppendRecordHeader appends the header of a Record with count fields,
+// each of which must follow as AppendFieldName and a value.
+func AppendRecordHeader(dst []byte, names *NameTable, name string, count int) []byte {
+	dst = append(dst, TypeRecord)
+	dst = names.appendName(dst, name)
+	return binary.AppendUvarint(dst, uint64(count))
+}
+
+// AppendFieldName appends the name of the next Record field.
+func AppendFieldName(dst []byte, names *NameTable, name string) []byte {
+	return names.appendName(dst, name)
+}
+
+// appendName writes a reference to name in the format of buffer.writeName,
+// adding it to the table as a literal the first time it is seen
+func (t *NameTable) appendName(dst []byte, name string) []byte {
+	for i := 0; i < t.n && i < len(t.small); i++ {
+		if t.small[i] == name {
+			return binary.AppendUvarint(dst, uint64(i)+1)
+		}
+	}
+	if i, ok := t.more[name]; ok {
+		return binary.AppendUvarint(dst, uint64(i)+1)
+	}
+	if t.n < len(t.small) {
+		t.small[t.n] = name
+	} else {
+		if t.more == nil {
+			t.more = make(map[string]int)
+		}
+		t.more[name] = t.n
+	}
+	t.n++
+	dst = append(dst, 0)
+	dst = binary.AppendUvarint(dst, uint64(len(name)))
+	return append(dst, name...)
+}
@@ -0,0 +1,234 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Packed arrays carry a homogeneous slice as one tag, a count and a
+// contiguous payload, and decode straight into a typed Go slice:
+//
+//	[TypeInt32Array][Count as varint][4 bytes little-endian each]
+//	[TypeInt64Array][Count as varint][8 bytes little-endian each]
+//	[TypeFloat64Array][Count as varint][8 bytes IEEE 754 little-endian each]
+//	[TypeBoolArray][Count as varint][bitmap, element i in bit i%8 of byte i/8]
+
+// AppendInt32Array appends a []int32 as a packed array.
+func AppendInt32Array(dst []byte, v []int32) []byte {
+	dst = append(dst, TypeInt32Array)
+	dst = binary.AppendUvarint(dst, uint64(len(v)))
+	for _, n := range v {
+		dst = binary.LittleEndian.AppendUint32(dst, uint32(n))
+	}
+	return dst
+}
+
+// AppendInt64Array appends a []int64 as a packed array.
+func AppendInt64Array(dst []byte, v []int64) []byte {
+	dst = append(dst, TypeInt64Array)
+	dst = binary.AppendUvarint(dst, uint64(len(v)))
+	for _, n := range v {
+		dst = binary.LittleEndian.AppendUint64(dst, uint64(n))
+	}
+	return dst
+}
+
+// AppendFloat64Array appends a []float64 as a packed array.
+func AppendFloat64Array(dst []byte, v []float64) []byte {
+	dst = append(dst, TypeFloat64Array)
+	dst = binary.AppendUvarint(dst, uint64(len(v)))
+	for _, f := range v {
+		dst = binary.LittleEndian.AppendUint64(dst, math.Float64bits(f))
+	}
+	return dst
+}
+
+// AppendBoolArray appends a []bool as a packed bitmap.
+func AppendBoolArray(dst []byte, v []bool) []byte {
+	dst = append(dst, TypeBoolArray)
+	dst = binary.AppendUvarint(dst, uint64(len(v)))
+	var b byte
+	for i, set := range v {
+		if set {
+			b |= 1 << (i % 8)
+		}
+		if i%8 == 7 {
+			dst = append(dst, b)
+			b = 0
+		}
+	}
+	if len(v)%8 != 0 {
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// readArrayHeader decodes the count of a packed array and checks that its
+// payload of elemBits bits per element is present
+func (d *decoder) readArrayHeader(offset int, tag byte, elemBits uint64) (int, int, error) {
+	count, offset, err := d.readVarint(offset, tag)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := d.checkCount(offset, tag, count); err != nil {
+		return 0, 0, err
+	}
+	// Compute the payload size without overflowing on hostile counts
+	remaining := uint64(len(d.data) - offset)
+	if count/8 > remaining/elemBits {
+		return 0, 0, d.errorf(offset, tag, ErrTruncated,
+			"array of %d elements exceeds remaining %d bytes", count, remaining)
+	}
+	size := (count*elemBits + 7) / 8
+	if size > remaining {
+		return 0, 0, d.errorf(offset, tag, ErrTruncated,
+			"array of %d elements exceeds remaining %d bytes", count, remaining)
+	}
+	return int(count), offset, nil
+}
+
+// decodeArray decodes the payload of a packed array element
+func (d *decoder) decodeArray(offset int, tag byte) (interface{}, int, error) {
+	data := d.data
+	switch tag {
+	case TypeInt32Array:
+		count, offset, err := d.readArrayHeader(offset, tag, 32)
+		if err != nil {
+			return nil, 0, err
+		}
+		v := make([]int32, count)
+		for i := range v {
+			v[i] = int32(binary.LittleEndian.Uint32(data[offset:]))
+			offset += 4
+		}
+		return v, offset, nil
+
+	case TypeInt64Array:
+		count, offset, err := d.readArrayHeader(offset, tag, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		v := make([]int64, count)
+		for i := range v {
+			v[i] = int64(binary.LittleEndian.Uint64(data[offset:]))
+			offset += 8
+		}
+		return v, offset, nil
+
+	case TypeFloat64Array:
+		count, offset, err := d.readArrayHeader(offset, tag, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		v := make([]float64, count)
+		for i := range v {
+			v[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[offset:]))
+			offset += 8
+		}
+		return v, offset, nil
+
+	default: // TypeBoolArray
+		count, offset, err := d.readArrayHeader(offset, tag, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		v := make([]bool, count)
+		for i := range v {
+			v[i] = data[offset+i/8]&(1<<(i%8)) != 0
+		}
+		return v, offset + (count+7)/8, nil
+	}
+}
+
+// compareArray compares packed array slices element by element; floats are
+// compared by bit pattern like scalar floats
+func compareArray(a, b interface{}) bool {
+	switch va := a.(type) {
+	case []int32:
+		vb, ok := b.([]int32)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if va[i] != vb[i] {
+				return false
+			}
+		}
+		return true
+	case []int64:
+		vb, ok := b.([]int64)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if va[i] != vb[i] {
+				return false
+			}
+		}
+		return true
+	case []float64:
+		vb, ok := b.([]float64)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if math.Float64bits(va[i]) != math.Float64bits(vb[i]) {
+				return false
+			}
+		}
+		return true
+	case []bool:
+		vb, ok := b.([]bool)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if va[i] != vb[i] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// formatArray formats a packed array slice as "[]int32{1, 2, 3}"
+func formatArray(v interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%T{", v))
+	switch val := v.(type) {
+	case []int32:
+		for i, n := range val {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.FormatInt(int64(n), 10))
+		}
+	case []int64:
+		for i, n := range val {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.FormatInt(n, 10))
+		}
+	case []float64:
+		for i, f := range val {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case []bool:
+		for i, b := range val {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.FormatBool(b))
+		}
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
@@ -0,0 +1,133 @@
+package protocol
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestPackedArrays tests packed array round trips and sizes
+func TestPackedArrays(t *testing.T) {
+	ints := make([]int32, 1000)
+	for i := range ints {
+		ints[i] = int32(i*7919) - 500000
+	}
+
+	tests := []struct {
+		name string
+		data interface{}
+		size int
+	}{
+		{"Int32 1000", ints, 1 + 2 + 4000},
+		{"Int32 empty", []int32{}, 2},
+		{"Int64", []int64{0, -1, math.MaxInt64, math.MinInt64}, 2 + 32},
+		{"Float64", []float64{1.5, math.NaN(), math.Inf(-1), math.Copysign(0, -1)}, 2 + 32},
+		{"Bool 8", []bool{true, false, true, true, false, false, false, true}, 2 + 1},
+		{"Bool 9", []bool{true, false, false, false, false, false, false, false, true}, 2 + 2},
+		{"Bool empty", []bool{}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := Encode(tt.data)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if len(encoded) != tt.size {
+				t.Errorf("encoded size = %d, want %d", len(encoded), tt.size)
+			}
+			decoded, err := Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if !compareDataInput(tt.data, decoded) {
+				t.Errorf("Encode/Decode mismatch: got %s", formatDataInput(decoded))
+			}
+		})
+	}
+
+	row := NewDataInput("vector", []float64{0.1, 0.2}, []bool{true}, []int32(nil))
+	if decoded := decode(encode(row)); !compareDataInput(row, decoded) {
+		t.Errorf("Encode/Decode mismatch: got %s", formatDataInput(decoded))
+	}
+	if got, want := formatDataInput(row), `DataInput{"vector", []float64{0.1, 0.2}, []bool{true}, []int32{}}`; got != want {
+		t.Errorf("formatDataInput = %s, want %s", got, want)
+	}
+	if compareDataInput([]int32{1}, []int64{1}) || compareDataInput([]int32{1}, NewDataInput(int32(1))) {
+		t.Error("compareDataInput treats different array types as equal")
+	}
+}
+
+// TestPackedArrayDecodeAllocs tests that a packed array decodes with a
+// single slice allocation instead of one interface per element
+func TestPackedArrayDecodeAllocs(t *testing.T) {
+	encoded, err := Encode(make([]int32, 1000))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := Decode(encoded); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// The slice, its interface header and the decoder state
+	if allocs > 3 {
+		t.Errorf("Decode of 1000 packed int32s made %.0f allocations, want at most 3", allocs)
+	}
+}
+
+// TestPackedArrayErrors tests hostile counts and limits
+func TestPackedArrayErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		kind error
+	}{
+		{"Truncated int32", []byte{TypeInt32Array, 0x02, 1, 0, 0, 0, 2}, ErrTruncated},
+		{"Truncated bitmap", []byte{TypeBoolArray, 0x09, 0xFF}, ErrTruncated},
+		{"Huge count", []byte{TypeInt64Array, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}, ErrLimitExceeded},
+		{"Huge bool count", []byte{TypeBoolArray, 0xFF, 0xFF, 0xFF, 0xFF, 0x0F}, ErrLimitExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); !errors.Is(err, tt.kind) {
+				t.Errorf("Decode error = %v, want %v", err, tt.kind)
+			}
+		})
+	}
+
+	huge := []byte{TypeFloat64Array, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}
+	if _, err := DecodeWithOptions(huge, DecoderOptions{}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Decode without limits: got %v, want ErrTruncated", err)
+	}
+}
+
+// BenchmarkInt32DataInputDecode decodes 1000 int32s as DataInput elements
+func BenchmarkInt32DataInputDecode(b *testing.B) {
+	data := NewDataInput()
+	for i := 0; i < 1000; i++ {
+		data.elements = append(data.elements, int32(i))
+	}
+	encoded := encode(data)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = decode(encoded)
+	}
+}
+
+// BenchmarkInt32ArrayDecode decodes 1000 int32s as a packed array
+func BenchmarkInt32ArrayDecode(b *testing.B) {
+	values := make([]int32, 1000)
+	for i := range values {
+		values[i] = int32(i)
+	}
+	encoded := encode(values)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = decode(encoded)
+	}
+}
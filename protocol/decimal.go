@@ -0,0 +1,146 @@
+package protocol
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// MaxDecimalScale bounds the scale of a Decimal in either direction. It
+// matches the 16383 digits after the decimal point of a PostgreSQL NUMERIC,
+// and keeps String from building strings of gigabytes from a few bytes of
+// input.
+const MaxDecimalScale = 16383
+
+// Decimal is an arbitrary-precision decimal number with the value
+// Unscaled × 10^-Scale, as used by SQL NUMERIC and DECIMAL columns.
+// A nil Unscaled is zero.
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int32
+}
+
+// NewDecimal returns the Decimal unscaled × 10^-scale.
+func NewDecimal(unscaled *big.Int, scale int32) Decimal {
+	return Decimal{Unscaled: unscaled, Scale: scale}
+}
+
+// ParseDecimal parses a plain decimal string such as "-12345.6789". The
+// scale is the number of digits after the decimal point.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart+fracPart == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if len(fracPart) > MaxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale %d exceeds %d", len(fracPart), MaxDecimalScale)
+	}
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+	}
+	unscaled, _ := new(big.Int).SetString(intPart+fracPart, 10)
+	if s[0] == '-' {
+		unscaled.Neg(unscaled)
+	}
+	return Decimal{Unscaled: unscaled, Scale: int32(len(fracPart))}, nil
+}
+
+// String returns the plain decimal representation, with exactly Scale
+// digits after the decimal point.
+func (d Decimal) String() string {
+	unscaled := d.Unscaled
+	if unscaled == nil {
+		unscaled = new(big.Int)
+	}
+	digits := new(big.Int).Abs(unscaled).String()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if d.Scale <= 0 {
+		if unscaled.Sign() == 0 {
+			return "0"
+		}
+		// Negate in int64, since -MinInt32 overflows int32
+		return sign + digits + strings.Repeat("0", int(-int64(d.Scale)))
+	}
+	scale := int64(d.Scale)
+	if int64(len(digits)) <= scale {
+		digits = strings.Repeat("0", int(scale-int64(len(digits))+1)) + digits
+	}
+	point := len(digits) - int(scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// appendBigInt writes an integer as [(len << 1) | sign as varint][magnitude
+// big-endian]
+func appendBigInt(buf *buffer, n *big.Int) {
+	var mag []byte
+	if n != nil {
+		mag = n.Bytes()
+	}
+	header := uint64(len(mag)) << 1
+	if n != nil && n.Sign() < 0 {
+		header |= 1
+	}
+	buf.Write(encodeVarint(header))
+	buf.Write(mag)
+}
+
+// readBigInt decodes an integer written by appendBigInt
+func (d *decoder) readBigInt(offset int, tag byte) (*big.Int, int, error) {
+	header, offset, err := d.readVarint(offset, tag)
+	if err != nil {
+		return nil, 0, err
+	}
+	length := header >> 1
+	if exceeds(length, d.opts.MaxStringBytes) {
+		return nil, 0, d.errorf(offset, tag, ErrLimitExceeded,
+			"magnitude length %d exceeds %d bytes", length, d.opts.MaxStringBytes)
+	}
+	if length > uint64(len(d.data)-offset) {
+		return nil, 0, d.errorf(offset, tag, ErrTruncated,
+			"magnitude length %d exceeds remaining %d bytes", length, len(d.data)-offset)
+	}
+	end := offset + int(length)
+	n := new(big.Int).SetBytes(d.data[offset:end])
+	if header&1 == 1 {
+		n.Neg(n)
+	}
+	return n, end, nil
+}
+
+// decodeDecimal decodes the payload of a TypeDecimal element
+func (d *decoder) decodeDecimal(offset int) (interface{}, int, error) {
+	scale, next, err := d.readVarint(offset, TypeDecimal)
+	if err != nil {
+		return nil, 0, err
+	}
+	s := zigzagDecode(scale)
+	if s < -MaxDecimalScale || s > MaxDecimalScale {
+		return nil, 0, d.errorf(offset, TypeDecimal, ErrInvalidValue,
+			"scale %d exceeds %d", s, MaxDecimalScale)
+	}
+	unscaled, next, err := d.readBigInt(next, TypeDecimal)
+	if err != nil {
+		return nil, 0, err
+	}
+	return Decimal{Unscaled: unscaled, Scale: int32(s)}, next, nil
+}
+
+// compareBigInt treats nil as zero
+func compareBigInt(a, b *big.Int) bool {
+	if a == nil {
+		a = new(big.Int)
+	}
+	if b == nil {
+		b = new(big.Int)
+	}
+	return a.Cmp(b) == 0
+}
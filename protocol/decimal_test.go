@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// TestDecimalParsing tests conversion between Decimal and its text form
+func TestDecimalParsing(t *testing.T) {
+	tests := []struct {
+		in       string
+		unscaled string
+		scale    int32
+		out      string
+	}{
+		{"-12345.6789", "-123456789", 4, "-12345.6789"},
+		{"0", "0", 0, "0"},
+		{"0.00", "0", 2, "0.00"},
+		{"+1.5", "15", 1, "1.5"},
+		{".05", "5", 2, "0.05"},
+		{"-0.001", "-1", 3, "-0.001"},
+		{"12345678901234567890123456789.0123456789", "123456789012345678901234567890123456789", 10, "12345678901234567890123456789.0123456789"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := ParseDecimal(tt.in)
+			if err != nil {
+				t.Fatalf("ParseDecimal failed: %v", err)
+			}
+			if d.Unscaled.String() != tt.unscaled || d.Scale != tt.scale {
+				t.Errorf("ParseDecimal = %s×10^-%d, want %s×10^-%d", d.Unscaled, d.Scale, tt.unscaled, tt.scale)
+			}
+			if d.String() != tt.out {
+				t.Errorf("String() = %s, want %s", d.String(), tt.out)
+			}
+		})
+	}
+
+	if s := NewDecimal(big.NewInt(-42), -3).String(); s != "-42000" {
+		t.Errorf("negative scale String() = %s, want -42000", s)
+	}
+	if s := (Decimal{}).String(); s != "0" {
+		t.Errorf("zero value String() = %s, want 0", s)
+	}
+
+	for _, bad := range []string{"", "-", ".", "1.2.3", "1e5", "--1", "12a", " 1"} {
+		if _, err := ParseDecimal(bad); err == nil {
+			t.Errorf("ParseDecimal(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+// TestDecimalEncoding tests Decimal and *big.Int round trips
+func TestDecimalEncoding(t *testing.T) {
+	price, _ := ParseDecimal("-12345.6789")
+	numeric, _ := ParseDecimal("12345678901234567890123456789.0123456789")
+	huge, _ := new(big.Int).SetString("-"+strings.Repeat("9", 100), 10)
+
+	tests := []struct {
+		name string
+		data interface{}
+	}{
+		{"Decimal", price},
+		{"NUMERIC(38,10)", numeric},
+		{"Zero decimal", Decimal{}},
+		{"Negative scale", NewDecimal(big.NewInt(7), -5)},
+		{"BigInt zero", new(big.Int)},
+		{"BigInt small", big.NewInt(-300)},
+		{"BigInt huge", huge},
+		{"Row", NewDataInput("amount", price, huge)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := Encode(tt.data)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			decoded, err := Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if !compareDataInput(tt.data, decoded) {
+				t.Errorf("Encode/Decode mismatch: got %s, want %s",
+					formatDataInput(decoded), formatDataInput(tt.data))
+			}
+		})
+	}
+
+	one, _ := ParseDecimal("1.0")
+	oneHundredths, _ := ParseDecimal("1.00")
+	if compareDataInput(one, oneHundredths) {
+		t.Error("compareDataInput ignores the decimal scale")
+	}
+	if _, err := Encode((*big.Int)(nil)); err == nil {
+		t.Error("Encode accepted a nil *big.Int")
+	}
+	if encoded := encode(big.NewInt(-1)); len(encoded) != 3 {
+		t.Errorf("BigInt(-1) encoded size = %d, want 3", len(encoded))
+	}
+
+	// Scale outside int32
+	bad := append([]byte{TypeDecimal}, encodeVarint(zigzagEncode(1<<40))...)
+	bad = append(bad, 0x00)
+	if _, err := Decode(bad); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Decode with 64-bit scale: got %v, want ErrInvalidValue", err)
+	}
+
+	// Scales near the int32 limits would overflow String or have it build
+	// gigabytes of zeros
+	for _, scale := range []int64{math.MinInt32, math.MaxInt32, -MaxDecimalScale - 1, MaxDecimalScale + 1} {
+		bad := append([]byte{TypeDecimal}, encodeVarint(zigzagEncode(scale))...)
+		bad = append(bad, 0x02, 0x01)
+		if _, err := Decode(bad); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("Decode with scale %d: got %v, want ErrInvalidValue", scale, err)
+		}
+		if _, err := Encode(NewDecimal(big.NewInt(1), int32(scale))); err == nil {
+			t.Errorf("Encode accepted scale %d", scale)
+		}
+	}
+	if _, err := ParseDecimal("0." + strings.Repeat("1", MaxDecimalScale+1)); err == nil {
+		t.Error("ParseDecimal accepted a scale over MaxDecimalScale")
+	}
+	if s := NewDecimal(big.NewInt(1), -MaxDecimalScale).String(); len(s) != 1+MaxDecimalScale {
+		t.Errorf("String with scale %d has length %d", -MaxDecimalScale, len(s))
+	}
+}
@@ -0,0 +1,229 @@
+package protocol
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Enums carry an index into a symbol table:
+//
+//	[TypeEnum][Table][Index as varint]
+//
+// Tables are interned per message like record names: varint 0 is followed
+// by a literal [Name][Symbol count as varint][Symbol]..., with the name and
+// each symbol written as [Length as varint][UTF-8 bytes], which becomes the
+// next table entry, and varint n refers to entry n-1. Tables listed in
+// EncoderOptions.Enums and DecoderOptions.Enums are declared by the schema
+// instead: they take the first entries of every message's table and are
+// never written, so both sides must list the same tables in the same order.
+
+// EnumType is a named, ordered table of symbols, such as the values of a
+// status column. It is shared by all values of the enum.
+type EnumType struct {
+	name    string
+	symbols []string
+	index   map[string]int
+	key     string // name and symbols, identifying equal tables in map keys
+}
+
+// NewEnumType creates an EnumType with the given symbols. Symbols must be
+// unique and valid UTF-8.
+func NewEnumType(name string, symbols ...string) (*EnumType, error) {
+	t := &EnumType{name: name, symbols: symbols, index: make(map[string]int, len(symbols))}
+	if !utf8.ValidString(name) {
+		return nil, fmt.Errorf("enum name %q is not valid UTF-8", name)
+	}
+	for i, s := range symbols {
+		if !utf8.ValidString(s) {
+			return nil, fmt.Errorf("enum %s: symbol %q is not valid UTF-8", name, s)
+		}
+		if existing, ok := t.index[s]; ok {
+			return nil, fmt.Errorf("enum %s: symbol %q repeats symbol %d", name, s, existing)
+		}
+		t.index[s] = i
+	}
+	t.setKey()
+	return t, nil
+}
+
+// Name returns the name of the enum.
+func (t *EnumType) Name() string {
+	return t.name
+}
+
+// Symbols returns the symbols of the enum in index order.
+func (t *EnumType) Symbols() []string {
+	return t.symbols
+}
+
+// Value returns the Enum for symbol.
+func (t *EnumType) Value(symbol string) (Enum, error) {
+	i, ok := t.index[symbol]
+	if !ok {
+		return Enum{}, fmt.Errorf("enum %s has no symbol %q", t.name, symbol)
+	}
+	return Enum{Type: t, Index: i}, nil
+}
+
+// Enum is a value of an EnumType, sent as its index into the symbol table.
+type Enum struct {
+	Type  *EnumType
+	Index int
+}
+
+// Symbol returns the symbol of the value, or "" if the index is out of
+// range.
+func (e Enum) Symbol() string {
+	if e.Type == nil || e.Index < 0 || e.Index >= len(e.Type.symbols) {
+		return ""
+	}
+	return e.Type.symbols[e.Index]
+}
+
+// String returns the value as "name.symbol".
+func (e Enum) String() string {
+	if e.Type == nil {
+		return fmt.Sprintf("Enum(%d)", e.Index)
+	}
+	if e.Index < 0 || e.Index >= len(e.Type.symbols) {
+		return fmt.Sprintf("%s(%d)", e.Type.name, e.Index)
+	}
+	return e.Type.name + "." + e.Type.symbols[e.Index]
+}
+
+// encodeEnum writes an Enum, declaring its table in the message the first
+// time it is used
+func encodeEnum(buf *buffer, e Enum) error {
+	if e.Type == nil {
+		return fmt.Errorf("enum value %d has no EnumType", e.Index)
+	}
+	if e.Index < 0 || e.Index >= len(e.Type.symbols) {
+		return fmt.Errorf("enum %s: index %d outside %d symbols", e.Type.name, e.Index, len(e.Type.symbols))
+	}
+	buf.WriteByte(TypeEnum)
+	buf.writeEnumType(e.Type)
+	buf.Write(encodeVarint(uint64(e.Index)))
+	return nil
+}
+
+// writeEnumType writes a reference to t, adding it to the enum table as a
+// literal the first time it is seen. Messages rarely use more than a few
+// enums, so a linear scan beats maintaining an index.
+func (b *buffer) writeEnumType(t *EnumType) {
+	for i, known := range b.enums {
+		if known == t {
+			b.Write(encodeVarint(uint64(i) + 1))
+			return
+		}
+	}
+	b.enums = append(b.enums, t)
+	b.WriteByte(0)
+	b.writeText(t.name)
+	b.Write(encodeVarint(uint64(len(t.symbols))))
+	for _, s := range t.symbols {
+		b.writeText(s)
+	}
+}
+
+// readEnumType decodes a table reference written by writeEnumType
+func (d *decoder) readEnumType(offset int) (*EnumType, int, error) {
+	ref, next, err := d.readVarint(offset, TypeEnum)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ref > 0 {
+		if ref > uint64(len(d.enums)) {
+			return nil, 0, d.errorf(offset, TypeEnum, ErrInvalidValue,
+				"enum reference %d outside table of %d enums", ref, len(d.enums))
+		}
+		return d.enums[ref-1], next, nil
+	}
+
+	name, next, err := d.readText(next, TypeEnum)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, next, err := d.readVarint(next, TypeEnum)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := d.checkCount(next, TypeEnum, count); err != nil {
+		return nil, 0, err
+	}
+	// Every symbol takes at least its length byte
+	if count > uint64(len(d.data)-next) {
+		return nil, 0, d.errorf(next, TypeEnum, ErrTruncated,
+			"symbol count %d exceeds remaining %d bytes", count, len(d.data)-next)
+	}
+
+	t := &EnumType{name: name, symbols: make([]string, 0, count), index: make(map[string]int, count)}
+	for i := 0; i < int(count); i++ {
+		symbolOffset := next
+		symbol, end, err := d.readText(next, TypeEnum)
+		if err != nil {
+			return nil, 0, err
+		}
+		if existing, ok := t.index[symbol]; ok {
+			return nil, 0, d.errorf(symbolOffset, TypeEnum, ErrDuplicateKey,
+				"symbol %q repeats symbol %d", symbol, existing)
+		}
+		t.index[symbol] = i
+		t.symbols = append(t.symbols, symbol)
+		next = end
+	}
+	t.setKey()
+	d.enums = append(d.enums, t)
+	return t, next, nil
+}
+
+// setKey builds t.key from the name and symbols
+func (t *EnumType) setKey() {
+	b := appendKeyBytes(nil, []byte(t.name))
+	for _, s := range t.symbols {
+		b = appendKeyBytes(b, []byte(s))
+	}
+	t.key = string(b)
+}
+
+// decodeEnum decodes the payload of a TypeEnum element, as a string with
+// DecoderOptions.EnumsAsStrings and as an Enum otherwise
+func (d *decoder) decodeEnum(offset int) (interface{}, int, error) {
+	t, offset, err := d.readEnumType(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	index, next, err := d.readVarint(offset, TypeEnum)
+	if err != nil {
+		return nil, 0, err
+	}
+	if index >= uint64(len(t.symbols)) {
+		return nil, 0, d.errorf(offset, TypeEnum, ErrInvalidValue,
+			"index %d outside enum %s of %d symbols", index, t.name, len(t.symbols))
+	}
+	if d.opts.EnumsAsStrings {
+		return t.symbols[index], next, nil
+	}
+	return Enum{Type: t, Index: int(index)}, next, nil
+}
+
+// compareEnum compares enum names and symbols, so a decoded value equals
+// the value it was encoded from
+func compareEnum(a Enum, b interface{}) bool {
+	eb, ok := b.(Enum)
+	if !ok || a.Index != eb.Index {
+		return false
+	}
+	if a.Type == eb.Type {
+		return true
+	}
+	if a.Type == nil || eb.Type == nil || a.Type.name != eb.Type.name ||
+		len(a.Type.symbols) != len(eb.Type.symbols) {
+		return false
+	}
+	for i := range a.Type.symbols {
+		if a.Type.symbols[i] != eb.Type.symbols[i] {
+			return false
+		}
+	}
+	return true
+}
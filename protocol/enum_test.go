@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// TestEnumEncoding tests enum values against a per-message symbol table
+func TestEnumEncoding(t *testing.T) {
+	status, err := NewEnumType("status", "active", "suspended", "closed")
+	if err != nil {
+		t.Fatalf("NewEnumType failed: %v", err)
+	}
+	active, err := status.Value("active")
+	if err != nil {
+		t.Fatalf("Value failed: %v", err)
+	}
+	closed, _ := status.Value("closed")
+
+	data := NewDataInput(active, closed, active, NewRecord("account", Field{"status", closed}))
+	encoded, err := Encode(data)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if n := bytes.Count(encoded, []byte("suspended")); n != 1 {
+		t.Errorf("symbol table written %d times, want 1", n)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !compareDataInput(data, decoded) {
+		t.Fatalf("Encode/Decode mismatch: got %s", formatDataInput(decoded))
+	}
+
+	got := decoded.(*DataInput).elements[1].(Enum)
+	if got.Symbol() != "closed" || got.Type.Name() != "status" {
+		t.Errorf("decoded %s, want status.closed", got)
+	}
+	if s := formatDataInput(active); s != "status.active" {
+		t.Errorf("formatDataInput = %s, want status.active", s)
+	}
+	if compareDataInput(active, closed) || compareDataInput(active, "active") {
+		t.Error("compareDataInput matched a different value")
+	}
+
+	opts := DefaultDecoderOptions()
+	opts.EnumsAsStrings = true
+	strs, err := DecodeWithOptions(encoded, opts)
+	if err != nil {
+		t.Fatalf("DecodeWithOptions failed: %v", err)
+	}
+	want := NewDataInput("active", "closed", "active", NewRecord("account", Field{"status", "closed"}))
+	if !compareDataInput(want, strs) {
+		t.Errorf("EnumsAsStrings decoded %s", formatDataInput(strs))
+	}
+}
+
+// TestEnumSchema tests enum tables declared through the options instead
+// of the message
+func TestEnumSchema(t *testing.T) {
+	status, _ := NewEnumType("status", "active", "suspended")
+	suspended, _ := status.Value("suspended")
+
+	encoded, err := EncodeWithOptions(suspended, EncoderOptions{Enums: []*EnumType{status}})
+	if err != nil {
+		t.Fatalf("EncodeWithOptions failed: %v", err)
+	}
+	// Tag, table reference and index
+	if !bytes.Equal(encoded, []byte{TypeEnum, 0x01, 0x01}) {
+		t.Errorf("encoded %x, want 3 bytes without a symbol table", encoded)
+	}
+
+	opts := DefaultDecoderOptions()
+	opts.Enums = []*EnumType{status}
+	decoded, err := DecodeWithOptions(encoded, opts)
+	if err != nil {
+		t.Fatalf("DecodeWithOptions failed: %v", err)
+	}
+	if decoded != suspended {
+		t.Errorf("decoded %v, want %v", decoded, suspended)
+	}
+
+	// Without the schema the reference is dangling
+	if _, err := Decode(encoded); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Decode error = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+// TestEnumErrors tests invalid enum types, values and messages
+func TestEnumErrors(t *testing.T) {
+	if _, err := NewEnumType("status", "a", "b", "a"); err == nil {
+		t.Error("NewEnumType accepted a repeated symbol")
+	}
+	status, _ := NewEnumType("status", "a", "b")
+	if _, err := status.Value("c"); err == nil {
+		t.Error("Value accepted an unknown symbol")
+	}
+	for _, e := range []Enum{{Type: status, Index: 2}, {Type: status, Index: -1}, {Index: 0}} {
+		if _, err := Encode(e); err == nil {
+			t.Errorf("Encode(%v) succeeded, want error", e)
+		}
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		kind error
+	}{
+		{"Index out of range", []byte{TypeEnum, 0x00, 0x01, 's', 0x02, 0x01, 'a', 0x01, 'b', 0x02}, ErrInvalidValue},
+		{"Empty table", []byte{TypeEnum, 0x00, 0x01, 's', 0x00, 0x00}, ErrInvalidValue},
+		{"Reference to empty table", []byte{TypeEnum, 0x01, 0x00}, ErrInvalidValue},
+		{"Repeated symbol", []byte{TypeEnum, 0x00, 0x01, 's', 0x02, 0x01, 'a', 0x01, 'a', 0x00}, ErrDuplicateKey},
+		{"Invalid UTF-8 symbol", []byte{TypeEnum, 0x00, 0x01, 's', 0x01, 0x02, 0xC3, 0x28, 0x00}, ErrInvalidUTF8},
+		{"Hostile symbol count", []byte{TypeEnum, 0x00, 0x01, 's', 0xFF, 0xFF, 0x03, 0x01, 'a'}, ErrTruncated},
+		{"Missing index", []byte{TypeEnum, 0x00, 0x01, 's', 0x01, 0x01, 'a'}, ErrTruncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); !errors.Is(err, tt.kind) {
+				t.Errorf("Decode error = %v, want %v", err, tt.kind)
+			}
+		})
+	}
+}
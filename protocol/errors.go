@@ -0,0 +1,67 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Error kinds reported by the decoder. A DecodeError wraps exactly one of
+// them, so callers can test for a kind with errors.Is.
+var (
+	ErrTruncated      = errors.New("truncated data")
+	ErrInvalidUTF8    = errors.New("invalid UTF-8 string")
+	ErrUnknownTag     = errors.New("unknown type tag")
+	ErrVarintOverflow = errors.New("varint overflows 64 bits")
+	ErrInvalidValue   = errors.New("invalid value")
+	ErrDuplicateKey   = errors.New("duplicate map key or record field")
+	ErrTrailingData   = errors.New("trailing data after top-level element")
+	ErrUnexpectedType = errors.New("unexpected element type")
+)
+
+// DecodeError describes where and why decoding failed.
+type DecodeError struct {
+	// Offset is the byte offset in the input at which the failure was
+	// detected.
+	Offset int
+	// Path is the index of the failing element within each enclosing
+	// DataInput, outermost first. It is empty for the top-level element.
+	Path []int
+	// Tag is the type tag of the element being decoded, or 0 if the tag
+	// itself could not be read.
+	Tag byte
+	// Kind is one of the Err* sentinel errors.
+	Kind error
+	// Detail gives additional context, such as the lengths involved.
+	Detail string
+}
+
+func (e *DecodeError) Error() string {
+	msg := fmt.Sprintf("protocol: decode %s at offset %d (tag 0x%02x): %v",
+		formatPath(e.Path), e.Offset, e.Tag, e.Kind)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Unwrap returns the error kind so that errors.Is matches the sentinels.
+func (e *DecodeError) Unwrap() error {
+	return e.Kind
+}
+
+// formatPath renders an element path as "[2][0][3]", or "root" for the
+// top-level element.
+func formatPath(path []int) string {
+	if len(path) == 0 {
+		return "root"
+	}
+	var sb strings.Builder
+	for _, i := range path {
+		sb.WriteByte('[')
+		sb.WriteString(strconv.Itoa(i))
+		sb.WriteByte(']')
+	}
+	return sb.String()
+}
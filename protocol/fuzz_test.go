@@ -0,0 +1,182 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"net/netip"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// fuzzSeeds returns encoded messages taken from the cases in
+// protocol_test.go
+func fuzzSeeds() [][]byte {
+	status, err := NewEnumType("status", "active", "suspended")
+	if err != nil {
+		panic(err)
+	}
+	values := []interface{}{
+		nil,
+		"Hello, World!",
+		"",
+		"Hello 世界 🌍",
+		int32(42),
+		int32(-2147483648),
+		int32(-16),
+		int32(1<<20 - 1),
+		int64(math.MinInt64),
+		uint64(math.MaxUint64),
+		true,
+		false,
+		[]byte{0xC3, 0x28, 0x00},
+		time.Date(1969, 7, 20, 20, 17, 40, 1, time.UTC),
+		time.Date(2025, 1, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*60*60)),
+		-90 * time.Minute,
+		NewDecimal(big.NewInt(-123456789), 4),
+		big.NewInt(1 << 62),
+		UUID{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8},
+		math.Pi,
+		math.NaN(),
+		float32(math.Inf(-1)),
+		NewDataInput(),
+		NewDataInput("foo", int32(42), "bar"),
+		NewDataInput("outer", NewDataInput("inner", int32(1)), int32(2)),
+		NewDataInput("level1", NewDataInput("level2", NewDataInput("level3", NewDataInput("level4", int32(42))))),
+		NewDataInput("", int32(0), NewDataInput(), int32(-2147483648), nil),
+		NewDataInput(strings.Repeat("data", 100), int32(7)),
+		NewMap(MapEntry{"id", int64(1)}, MapEntry{int32(2), NewDataInput("v")}),
+		NewDataInput(NewRecord("row", Field{"id", int32(1)}, Field{"row", NewRecord("row")}), NewRecord("row", Field{"id", int32(2)})),
+		NewDataInput([]int32{1, -2}, []int64{math.MaxInt64}, []float64{math.NaN()}, []bool{true, false, true}),
+		NewDataInput(TypedNull{TypeInt32}, TypedNull{TypeString}, nil),
+		NewDataInput(Enum{status, 1}, Enum{status, 0}, TypedNull{TypeEnum}),
+		NewDataInput(netip.MustParseAddr("192.0.2.1"), netip.MustParsePrefix("2001:db8::/32")),
+		NewDataInput(RawExtension{Tag: 0xF3, Payload: []byte("ext")}, "after"),
+	}
+	seeds := make([][]byte, 0, len(values))
+	for _, v := range values {
+		encoded, err := Encode(v)
+		if err != nil {
+			panic(err)
+		}
+		seeds = append(seeds, encoded)
+	}
+	return seeds
+}
+
+// FuzzDecode checks that arbitrary input either decodes or returns an
+// error, with or without decoder limits, and that a Decoder reading it in
+// single bytes, a Tokenizer and a View agree
+func FuzzDecode(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Add([]byte{TypeString, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01})
+	f.Add([]byte{TypeDataInput, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := Decode(data)
+		if err == nil {
+			// Anything that decodes must encode again
+			if _, err := Encode(v); err != nil {
+				t.Fatalf("decoded value does not re-encode: %v", err)
+			}
+		}
+		_, _ = DecodeWithOptions(data, DecoderOptions{})
+
+		// The streaming decoder must find the same message boundary
+		dec := NewDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+		sv, serr := dec.Decode()
+		switch {
+		case err == nil:
+			if serr != nil || !compareDataInput(v, sv) {
+				t.Fatalf("Decoder = %s, %v, want %s", formatDataInput(sv), serr, formatDataInput(v))
+			}
+			if _, err := dec.Decode(); err != io.EOF {
+				t.Fatalf("Decoder at end = %v, want io.EOF", err)
+			}
+		case !errors.Is(err, ErrTrailingData) && serr == nil:
+			t.Fatalf("Decoder succeeded where Decode failed: %v", err)
+		}
+
+		// The Tokenizer must accept the same messages, though it does not
+		// look for duplicate map keys
+		tz := NewTokenizer(data)
+		var terr error
+		for terr == nil {
+			_, terr = tz.Next()
+		}
+		if terr == io.EOF {
+			terr = nil
+		}
+		if (err == nil) != (terr == nil) && !errors.Is(err, ErrDuplicateKey) {
+			t.Fatalf("Tokenizer = %v, want %v", terr, err)
+		}
+
+		// A View must reach every value of a message that decodes
+		if verr := walkView(NewView(data)); err == nil && verr != nil {
+			t.Fatalf("View failed where Decode succeeded: %v", verr)
+		}
+	})
+}
+
+// walkView reads every value reachable from v and returns the first error
+// in reaching one
+func walkView(v View) error {
+	if err := v.Err(); err != nil {
+		return err
+	}
+	v.String()
+	v.Int32()
+	var first error
+	keep := func(err error) {
+		if first == nil {
+			first = err
+		}
+	}
+	if v.Type() == TypeRecord {
+		_, err := v.Name()
+		keep(err)
+	}
+	for i := 0; i < v.Len(); i++ {
+		switch v.Type() {
+		case TypeMap:
+			keep(walkView(v.Key(i)))
+		case TypeRecord:
+			_, err := v.Field(i)
+			keep(err)
+		}
+		keep(walkView(v.Index(i)))
+	}
+	return first
+}
+
+// FuzzRoundTrip checks that every decodable message decodes to the same
+// value after being re-encoded
+func FuzzRoundTrip(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := Decode(data)
+		if err != nil {
+			return
+		}
+		encoded, err := Encode(v)
+		if err != nil {
+			t.Fatalf("Encode(%s) failed: %v", formatDataInput(v), err)
+		}
+		again, err := Decode(encoded)
+		if err != nil {
+			t.Fatalf("Decode of re-encoded %s failed: %v", formatDataInput(v), err)
+		}
+		if !compareDataInput(v, again) {
+			t.Fatalf("round trip mismatch: %s != %s", formatDataInput(v), formatDataInput(again))
+		}
+	})
+}
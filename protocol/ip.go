@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// IP addresses and networks are sent as their raw address bytes:
+//
+//	[TypeIPAddr][Address length, 4 or 16][Address bytes]
+//	[TypeIPPrefix][Address length, 4 or 16][Address bytes][Prefix length]
+//
+// IPv4-mapped IPv6 addresses keep their 16 byte form. IPv6 zones are not
+// carried, and prefixes keep the address as given rather than masked.
+
+// encodeIPAddr writes a netip.Addr
+func encodeIPAddr(buf *buffer, a netip.Addr) error {
+	if !a.IsValid() {
+		return fmt.Errorf("invalid netip.Addr")
+	}
+	if a.Zone() != "" {
+		return fmt.Errorf("netip.Addr %s: zones are not supported", a)
+	}
+	buf.WriteByte(TypeIPAddr)
+	writeAddrBytes(buf, a)
+	return nil
+}
+
+// encodeIPPrefix writes a netip.Prefix
+func encodeIPPrefix(buf *buffer, p netip.Prefix) error {
+	if !p.IsValid() {
+		return fmt.Errorf("invalid netip.Prefix")
+	}
+	buf.WriteByte(TypeIPPrefix)
+	writeAddrBytes(buf, p.Addr())
+	buf.WriteByte(byte(p.Bits()))
+	return nil
+}
+
+// writeAddrBytes writes the address length followed by the address
+func writeAddrBytes(buf *buffer, a netip.Addr) {
+	if a.Is4() {
+		b := a.As4()
+		buf.WriteByte(4)
+		buf.Write(b[:])
+		return
+	}
+	b := a.As16()
+	buf.WriteByte(16)
+	buf.Write(b[:])
+}
+
+// readAddr decodes an address written by writeAddrBytes
+func (d *decoder) readAddr(offset int, tag byte) (netip.Addr, int, error) {
+	if err := d.need(offset, tag, 1); err != nil {
+		return netip.Addr{}, 0, err
+	}
+	size := int(d.data[offset])
+	if size != 4 && size != 16 {
+		return netip.Addr{}, 0, d.errorf(offset, tag, ErrInvalidValue,
+			"address length %d, want 4 or 16", size)
+	}
+	offset++
+	if err := d.need(offset, tag, size); err != nil {
+		return netip.Addr{}, 0, err
+	}
+	a, _ := netip.AddrFromSlice(d.data[offset : offset+size])
+	return a, offset + size, nil
+}
+
+// decodeIPAddr decodes the payload of a TypeIPAddr element
+func (d *decoder) decodeIPAddr(offset int) (interface{}, int, error) {
+	a, offset, err := d.readAddr(offset, TypeIPAddr)
+	if err != nil {
+		return nil, 0, err
+	}
+	return a, offset, nil
+}
+
+// decodeIPPrefix decodes the payload of a TypeIPPrefix element
+func (d *decoder) decodeIPPrefix(offset int) (interface{}, int, error) {
+	a, offset, err := d.readAddr(offset, TypeIPPrefix)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := d.need(offset, TypeIPPrefix, 1); err != nil {
+		return nil, 0, err
+	}
+	bits := int(d.data[offset])
+	if bits > a.BitLen() {
+		return nil, 0, d.errorf(offset, TypeIPPrefix, ErrInvalidValue,
+			"prefix length %d exceeds %d bit address", bits, a.BitLen())
+	}
+	return netip.PrefixFrom(a, bits), offset + 1, nil
+}
@@ -0,0 +1,88 @@
+package protocol
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+// TestIPEncoding tests IPv4 and IPv6 addresses and prefixes
+func TestIPEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		size  int
+	}{
+		{"IPv4", netip.MustParseAddr("192.0.2.1"), 6},
+		{"IPv6", netip.MustParseAddr("2001:db8::1"), 18},
+		{"IPv4-mapped IPv6", netip.MustParseAddr("::ffff:192.0.2.1"), 18},
+		{"IPv4 prefix", netip.MustParsePrefix("10.0.0.0/8"), 7},
+		{"IPv6 prefix", netip.MustParsePrefix("2001:db8::/32"), 19},
+		{"Unmasked prefix", netip.MustParsePrefix("10.1.2.3/8"), 7},
+		{"Host prefix", netip.MustParsePrefix("::1/128"), 19},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := Encode(tt.value)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if len(encoded) != tt.size {
+				t.Errorf("encoded size = %d, want %d", len(encoded), tt.size)
+			}
+			decoded, err := Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if !compareDataInput(tt.value, decoded) {
+				t.Errorf("Encode/Decode mismatch: got %s, want %s",
+					formatDataInput(decoded), formatDataInput(tt.value))
+			}
+		})
+	}
+
+	client := netip.MustParseAddr("192.0.2.1")
+	if compareDataInput(client, "192.0.2.1") || compareDataInput(client, netip.MustParseAddr("::ffff:192.0.2.1")) {
+		t.Error("compareDataInput matched a different value")
+	}
+	if s := formatDataInput(NewDataInput(client, netip.MustParsePrefix("10.0.0.0/8"))); s != "DataInput{192.0.2.1, 10.0.0.0/8}" {
+		t.Errorf("formatDataInput = %s", s)
+	}
+
+	m := NewMap(MapEntry{client, int32(1)})
+	if v, ok := m.Get(netip.MustParseAddr("192.0.2.1")); !ok || v != int32(1) {
+		t.Errorf("Map.Get(address) = %v, %v; want 1, true", v, ok)
+	}
+}
+
+// TestIPErrors tests unencodable values and malformed messages
+func TestIPErrors(t *testing.T) {
+	for _, v := range []interface{}{
+		netip.Addr{},
+		netip.Prefix{},
+		netip.MustParseAddr("fe80::1%eth0"),
+	} {
+		if _, err := Encode(v); err == nil {
+			t.Errorf("Encode(%v) succeeded, want error", v)
+		}
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		kind error
+	}{
+		{"Bad address length", []byte{TypeIPAddr, 0x05, 1, 2, 3, 4, 5}, ErrInvalidValue},
+		{"Truncated address", []byte{TypeIPAddr, 0x10, 1, 2, 3, 4}, ErrTruncated},
+		{"Missing address length", []byte{TypeIPAddr}, ErrTruncated},
+		{"Prefix too long", []byte{TypeIPPrefix, 0x04, 10, 0, 0, 0, 33}, ErrInvalidValue},
+		{"Missing prefix length", []byte{TypeIPPrefix, 0x04, 10, 0, 0, 0}, ErrTruncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); !errors.Is(err, tt.kind) {
+				t.Errorf("Decode error = %v, want %v", err, tt.kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"net/netip"
+	"time"
+)
+
+// MapEntry is a single key-value pair of a Map.
+type MapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Map is an ordered collection of key-value pairs. Keys may be any
+// encodable value and are compared with Equal; entries keep their insertion
+// order on the wire and after decoding.
+type Map struct {
+	entries []MapEntry
+	index   map[interface{}]int // position of keys by mapIndexKey
+}
+
+// NewMap creates a Map holding the given entries. Later entries replace
+// earlier ones with an equal key.
+func NewMap(entries ...MapEntry) *Map {
+	m := &Map{}
+	for _, e := range entries {
+		m.Set(e.Key, e.Value)
+	}
+	return m
+}
+
+// Len returns the number of entries in the Map.
+func (m *Map) Len() int {
+	return len(m.entries)
+}
+
+// Entries returns the entries of the Map in insertion order.
+func (m *Map) Entries() []MapEntry {
+	return m.entries
+}
+
+// Get returns the value stored under key.
+func (m *Map) Get(key interface{}) (interface{}, bool) {
+	if i := m.find(key); i >= 0 {
+		return m.entries[i].Value, true
+	}
+	return nil, false
+}
+
+// Set stores value under key, replacing the value of an existing equal key
+// in place or appending a new entry.
+func (m *Map) Set(key, value interface{}) {
+	k, indexed := mapIndexKey(key, DefaultRegistry)
+	if i := m.findKey(key, k, indexed); i >= 0 {
+		m.entries[i].Value = value
+		return
+	}
+	m.add(key, value, k, indexed)
+}
+
+// add appends an entry without checking for an existing key. k is the index
+// form of key if indexed is set.
+func (m *Map) add(key, value, k interface{}, indexed bool) {
+	if indexed {
+		if m.index == nil {
+			m.index = make(map[interface{}]int)
+		}
+		m.index[k] = len(m.entries)
+	}
+	m.entries = append(m.entries, MapEntry{Key: key, Value: value})
+}
+
+// find returns the position of key, or -1
+func (m *Map) find(key interface{}) int {
+	k, indexed := mapIndexKey(key, DefaultRegistry)
+	return m.findKey(key, k, indexed)
+}
+
+// findKey is find given the index form of key from mapIndexKey
+func (m *Map) findKey(key, k interface{}, indexed bool) int {
+	if indexed {
+		if i, found := m.index[k]; found {
+			return i
+		}
+		return -1
+	}
+	for i, e := range m.entries {
+		if compareDataInput(e.Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// floatKey distinguishes float bit patterns from integer keys in the index
+type floatKey struct {
+	bits uint64
+	size int
+}
+
+// keyBytes is the index form of keys that are not comparable Go values,
+// built by appendMapKey
+type keyBytes string
+
+// mapIndexKey returns a hashable value that is equal for two keys exactly
+// when compareDataInput considers them equal. Only values of types that
+// have no codec in reg lack such a form; they are found by linear search.
+func mapIndexKey(key interface{}, reg *Registry) (interface{}, bool) {
+	if isNull(key) {
+		return nil, true
+	}
+	switch k := key.(type) {
+	case string, int32, int64, uint64, bool, time.Duration, UUID, TypedNull,
+		netip.Addr, netip.Prefix:
+		return k, true
+	case int:
+		return int64(k), true
+	case uint:
+		return uint64(k), true
+	case float64:
+		return floatKey{math.Float64bits(k), 64}, true
+	case float32:
+		return floatKey{uint64(math.Float32bits(k)), 32}, true
+	}
+	b, ok := appendMapKey(nil, key, reg)
+	return keyBytes(b), ok
+}
+
+// appendMapKey appends a byte form of key that is equal for two keys
+// exactly when compareDataInput considers them equal. Each part starts with
+// its type tag and is either fixed-size or length-prefixed, so the parts of
+// a container cannot run into each other. Extension values are represented
+// by their encoding.
+func appendMapKey(b []byte, key interface{}, reg *Registry) ([]byte, bool) {
+	switch k := key.(type) {
+	case nil:
+		return append(b, TypeNull), true
+	case TypedNull:
+		return append(b, TypeTypedNull, k.Type), true
+	case string:
+		return appendKeyBytes(append(b, TypeString), []byte(k)), true
+	case []byte:
+		return appendKeyBytes(append(b, TypeBytes), k), true
+	case bool:
+		if k {
+			return append(b, TypeTrue), true
+		}
+		return append(b, TypeFalse), true
+	case int32:
+		return binary.AppendVarint(append(b, TypeInt32), int64(k)), true
+	case int64:
+		return binary.AppendVarint(append(b, TypeInt64), k), true
+	case int:
+		return binary.AppendVarint(append(b, TypeInt64), int64(k)), true
+	case uint64:
+		return binary.AppendUvarint(append(b, TypeUint64), k), true
+	case uint:
+		return binary.AppendUvarint(append(b, TypeUint64), uint64(k)), true
+	case float64:
+		return binary.LittleEndian.AppendUint64(append(b, TypeFloat64), math.Float64bits(k)), true
+	case float32:
+		return binary.LittleEndian.AppendUint32(append(b, TypeFloat32), math.Float32bits(k)), true
+	case time.Duration:
+		return binary.AppendVarint(append(b, TypeDuration), int64(k)), true
+	case time.Time:
+		// The same instant and zone offset in minutes, as compareDataInput
+		// requires
+		_, offset := k.Zone()
+		b = binary.AppendVarint(append(b, TypeTime), k.Unix())
+		b = binary.AppendUvarint(b, uint64(k.Nanosecond()))
+		return binary.AppendVarint(b, int64(offset/60)), true
+	case UUID:
+		return append(append(b, TypeUUID), k[:]...), true
+	case Decimal:
+		b = binary.AppendVarint(append(b, TypeDecimal), int64(k.Scale))
+		return appendKeyBigInt(b, k.Unscaled), true
+	case *big.Int:
+		return appendKeyBigInt(append(b, TypeBigInt), k), true
+	case netip.Addr:
+		addr, _ := k.MarshalBinary()
+		return appendKeyBytes(append(b, TypeIPAddr), addr), true
+	case netip.Prefix:
+		prefix, _ := k.MarshalBinary()
+		return appendKeyBytes(append(b, TypeIPPrefix), prefix), true
+	case []int32:
+		b = binary.AppendUvarint(append(b, TypeInt32Array), uint64(len(k)))
+		for _, n := range k {
+			b = binary.AppendVarint(b, int64(n))
+		}
+		return b, true
+	case []int64:
+		b = binary.AppendUvarint(append(b, TypeInt64Array), uint64(len(k)))
+		for _, n := range k {
+			b = binary.AppendVarint(b, n)
+		}
+		return b, true
+	case []float64:
+		b = binary.AppendUvarint(append(b, TypeFloat64Array), uint64(len(k)))
+		for _, f := range k {
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+		}
+		return b, true
+	case []bool:
+		b = binary.AppendUvarint(append(b, TypeBoolArray), uint64(len(k)))
+		for _, v := range k {
+			if v {
+				b = append(b, 1)
+			} else {
+				b = append(b, 0)
+			}
+		}
+		return b, true
+	case *DataInput:
+		if k == nil {
+			return append(b, TypeNull), true
+		}
+		b = binary.AppendUvarint(append(b, TypeDataInput), uint64(len(k.elements)))
+		for _, e := range k.elements {
+			var ok bool
+			if b, ok = appendMapKey(b, e, reg); !ok {
+				return nil, false
+			}
+		}
+		return b, true
+	case *Map:
+		if k == nil {
+			return append(b, TypeNull), true
+		}
+		b = binary.AppendUvarint(append(b, TypeMap), uint64(len(k.entries)))
+		for _, e := range k.entries {
+			var ok bool
+			if b, ok = appendMapKey(b, e.Key, reg); !ok {
+				return nil, false
+			}
+			if b, ok = appendMapKey(b, e.Value, reg); !ok {
+				return nil, false
+			}
+		}
+		return b, true
+	case *Record:
+		if k == nil {
+			return append(b, TypeNull), true
+		}
+		b = appendKeyBytes(append(b, TypeRecord), []byte(k.name))
+		b = binary.AppendUvarint(b, uint64(len(k.fields)))
+		for _, f := range k.fields {
+			b = appendKeyBytes(b, []byte(f.Name))
+			var ok bool
+			if b, ok = appendMapKey(b, f.Value, reg); !ok {
+				return nil, false
+			}
+		}
+		return b, true
+	case Enum:
+		// Enums of equal tables are equal, so the table is identified by
+		// its contents
+		b = append(b, TypeEnum)
+		if k.Type != nil {
+			b = appendKeyBytes(append(b, 1), []byte(k.Type.key))
+		} else {
+			b = append(b, 0)
+		}
+		return binary.AppendVarint(b, int64(k.Index)), true
+	case RawExtension:
+		return appendKeyBytes(append(b, k.Tag, 0), k.Payload), true
+	}
+
+	if reg == nil {
+		reg = DefaultRegistry
+	}
+	c := reg.codecFor(key)
+	if c == nil {
+		return nil, false
+	}
+	payload, err := c.Encode(nil, key)
+	if err != nil {
+		return nil, false
+	}
+	return appendKeyBytes(append(b, c.Tag(), 1), payload), true
+}
+
+// appendKeyBytes appends p with its length
+func appendKeyBytes(b, p []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(p)))
+	return append(b, p...)
+}
+
+// appendKeyBigInt appends an integer as its sign and magnitude, with nil as
+// zero
+func appendKeyBigInt(b []byte, n *big.Int) []byte {
+	if n == nil {
+		n = new(big.Int)
+	}
+	return appendKeyBytes(append(b, byte(n.Sign()+1)), n.Bytes())
+}
+
+// decodeMap decodes the payload of a TypeMap element. Duplicate keys are an
+// error with DecoderOptions.StrictMaps; otherwise the last value wins.
+func (d *decoder) decodeMap(offset int) (interface{}, int, error) {
+	count, offset, err := d.readVarint(offset, TypeMap)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Every entry takes at least two bytes, a key and a value tag
+	if err := d.checkContainer(offset, TypeMap, count, 2); err != nil {
+		return nil, 0, err
+	}
+
+	m := &Map{entries: make([]MapEntry, 0, count)}
+	d.path = append(d.path, 0)
+	for i := 0; i < int(count); i++ {
+		d.path[len(d.path)-1] = i
+		keyOffset := offset
+		key, next, err := d.decodeElement(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, next, err := d.decodeElement(next)
+		if err != nil {
+			return nil, 0, err
+		}
+		k, indexed := mapIndexKey(key, d.opts.Registry)
+		if existing := m.findKey(key, k, indexed); existing >= 0 {
+			if d.opts.StrictMaps {
+				return nil, 0, d.errorf(keyOffset, TypeMap, ErrDuplicateKey,
+					"key %s repeats entry %d", formatDataInput(key), existing)
+			}
+			m.entries[existing].Value = value
+		} else {
+			m.add(key, value, k, indexed)
+		}
+		offset = next
+	}
+	d.path = d.path[:len(d.path)-1]
+
+	return m, offset, nil
+}
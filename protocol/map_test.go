@@ -0,0 +1,182 @@
+package protocol
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// TestMapEncoding tests ordered maps with arbitrary keys
+func TestMapEncoding(t *testing.T) {
+	m := NewMap(
+		MapEntry{"name", "alice"},
+		MapEntry{int32(7), 3.5},
+		MapEntry{NewDataInput("composite", int32(1)), true},
+		MapEntry{nil, NewMap()},
+	)
+	m.Set("zeta", int64(-1))
+	m.Set("name", "bob") // replaces in place
+
+	data := NewDataInput("row", m)
+	encoded, err := Encode(data)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !compareDataInput(data, decoded) {
+		t.Fatalf("Encode/Decode mismatch: got %s", formatDataInput(decoded))
+	}
+
+	got := decoded.(*DataInput).elements[1].(*Map)
+	wantKeys := []interface{}{"name", int32(7), NewDataInput("composite", int32(1)), nil, "zeta"}
+	if got.Len() != len(wantKeys) {
+		t.Fatalf("Len() = %d, want %d", got.Len(), len(wantKeys))
+	}
+	for i, e := range got.Entries() {
+		if !compareDataInput(e.Key, wantKeys[i]) {
+			t.Errorf("entry %d key = %s, want %s", i, formatDataInput(e.Key), formatDataInput(wantKeys[i]))
+		}
+	}
+	if v, ok := got.Get("name"); !ok || v != "bob" {
+		t.Errorf(`Get("name") = %v, %v; want "bob", true`, v, ok)
+	}
+	if v, ok := got.Get(NewDataInput("composite", int32(1))); !ok || v != true {
+		t.Errorf("Get(composite key) = %v, %v; want true, true", v, ok)
+	}
+	if _, ok := got.Get(int64(7)); ok {
+		t.Error("Get(int64(7)) matched an int32 key")
+	}
+
+	want := `Map{"a": 1, 2: DataInput{}}`
+	if s := formatDataInput(NewMap(MapEntry{"a", int32(1)}, MapEntry{int32(2), NewDataInput()})); s != want {
+		t.Errorf("formatDataInput = %s, want %s", s, want)
+	}
+}
+
+// TestMapEquality tests that entry order is significant
+func TestMapEquality(t *testing.T) {
+	a := NewMap(MapEntry{"x", int32(1)}, MapEntry{"y", int32(2)})
+	b := NewMap(MapEntry{"y", int32(2)}, MapEntry{"x", int32(1)})
+	if compareDataInput(a, b) {
+		t.Error("maps with different entry order compare equal")
+	}
+	if !compareDataInput(a, NewMap(MapEntry{"x", int32(1)}, MapEntry{"y", int32(2)})) {
+		t.Error("identical maps compare unequal")
+	}
+}
+
+// TestMapDuplicateKeys tests strict and lenient handling of repeated keys
+func TestMapDuplicateKeys(t *testing.T) {
+	// Map{"k": 1, "j": 2, "k": 3} built by hand, since Map itself never
+	// holds duplicates
+	encoded := []byte{TypeMap, 0x03,
+		TypeString, 0x01, 'k', TypeInt32, 1, 0, 0, 0,
+		TypeString, 0x01, 'j', TypeInt32, 2, 0, 0, 0,
+		TypeString, 0x01, 'k', TypeInt32, 3, 0, 0, 0,
+	}
+
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	want := NewMap(MapEntry{"k", int32(3)}, MapEntry{"j", int32(2)})
+	if !compareDataInput(decoded, want) {
+		t.Errorf("lenient decode = %s, want %s", formatDataInput(decoded), formatDataInput(want))
+	}
+
+	opts := DefaultDecoderOptions()
+	opts.StrictMaps = true
+	_, err = DecodeWithOptions(encoded, opts)
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("strict decode error = %v, want ErrDuplicateKey", err)
+	}
+	var de *DecodeError
+	if errors.As(err, &de) && (de.Offset != 18 || len(de.Path) != 1 || de.Path[0] != 2) {
+		t.Errorf("DecodeError offset %d path %v, want 18 [2]", de.Offset, de.Path)
+	}
+
+	// The entry count is bounded by the remaining input
+	if _, err := Decode([]byte{TypeMap, 0x02, TypeNull, TypeNull}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Decode of short map: got %v, want ErrTruncated", err)
+	}
+}
+
+// TestMapKeyIndex tests that keys of every type are found through the index
+// exactly when they are equal
+func TestMapKeyIndex(t *testing.T) {
+	east := time.Date(2025, 1, 1, 10, 0, 0, 0, time.FixedZone("A", 3600))
+	status, _ := NewEnumType("status", "active", "suspended")
+	again, _ := NewEnumType("status", "active", "suspended")
+	other, _ := NewEnumType("status", "active", "closed")
+
+	equal := [][2]interface{}{
+		{[]byte{1, 2}, []byte{1, 2}},
+		{[]byte(nil), []byte{}},
+		{east, east.In(time.FixedZone("B", 3600))},
+		{Decimal{Scale: 2}, NewDecimal(new(big.Int), 2)},
+		{big.NewInt(-5), big.NewInt(-5)},
+		{Enum{status, 1}, Enum{again, 1}},
+		{NewDataInput("a", []byte{1}), NewDataInput("a", []byte{1})},
+		{NewRecord("r", Field{"id", int32(1)}), NewRecord("r", Field{"id", int32(1)})},
+		{RawExtension{Tag: 0xF3, Payload: []byte("x")}, RawExtension{Tag: 0xF3, Payload: []byte("x")}},
+		{[]int32{1, 2}, []int32{1, 2}},
+	}
+	for _, pair := range equal {
+		m := NewMap(MapEntry{pair[0], int32(1)}, MapEntry{pair[1], int32(2)})
+		if v, ok := m.Get(pair[1]); m.Len() != 1 || !ok || v != int32(2) {
+			t.Errorf("keys %s and %s are not merged", formatDataInput(pair[0]), formatDataInput(pair[1]))
+		}
+	}
+
+	distinct := [][2]interface{}{
+		{[]byte("x"), "x"},
+		{NewDataInput("a", "b"), NewDataInput("ab")},
+		{NewDataInput(int32(1)), NewDataInput(int64(1))},
+		{east, east.In(time.UTC)},
+		{Decimal{Scale: 1}, Decimal{Scale: 2}},
+		{Enum{status, 1}, Enum{other, 1}},
+		{NewRecord("r", Field{"a", "b"}), NewRecord("ra", Field{"", "b"})},
+		{RawExtension{Tag: 0xF3, Payload: []byte("x")}, RawExtension{Tag: 0xF4, Payload: []byte("x")}},
+	}
+	for _, pair := range distinct {
+		if m := NewMap(MapEntry{pair[0], int32(1)}, MapEntry{pair[1], int32(2)}); m.Len() != 2 {
+			t.Errorf("keys %s and %s are merged", formatDataInput(pair[0]), formatDataInput(pair[1]))
+		}
+	}
+}
+
+// TestMapManyKeys tests that a map of many byte slice keys decodes in linear
+// time; a linear search for duplicates would take many seconds
+func TestMapManyKeys(t *testing.T) {
+	const count = 40000
+	// encodeKeys encodes a map of count distinct 4-byte keys and then the
+	// extra keys, all with null values
+	encodeKeys := func(extra ...uint32) []byte {
+		encoded := append([]byte{TypeMap}, encodeVarint(uint64(count+len(extra)))...)
+		for i := uint32(0); i < count+uint32(len(extra)); i++ {
+			k := i
+			if i >= count {
+				k = extra[i-count]
+			}
+			encoded = append(encoded, TypeBytes, 4, byte(k>>24), byte(k>>16), byte(k>>8), byte(k), TypeNull)
+		}
+		return encoded
+	}
+
+	opts := DefaultDecoderOptions()
+	opts.StrictMaps = true
+	v, err := DecodeWithOptions(encodeKeys(), opts)
+	if err != nil {
+		t.Fatalf("DecodeWithOptions failed: %v", err)
+	}
+	if n := v.(*Map).Len(); n != count {
+		t.Errorf("decoded %d entries, want %d", n, count)
+	}
+	if _, err := DecodeWithOptions(encodeKeys(7), opts); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("repeated key: got %v, want ErrDuplicateKey", err)
+	}
+}
@@ -0,0 +1,580 @@
+package protocol
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Marshal and Unmarshal map Go values onto protocol values:
+//
+//	struct                  *Record named after the Go type
+//	slice                   *DataInput, or nil for a nil slice
+//	pointer                 the value pointed to, or nil
+//	int8, int16, int32      int32
+//	int, int64              int64
+//	uint kinds              uint64
+//	string, bool, floats    themselves
+//	interface               its dynamic value
+//
+// Values the encoder supports natively, such as time.Time, UUID, []byte,
+// packed []int32 arrays or *Map, and types with a codec in DefaultRegistry
+// are passed through unchanged; nil pointers among them, and zero Enum,
+// netip.Addr and netip.Prefix values, which cannot be encoded, are sent as
+// null. Named types convert
+// through their kind, so `type Status string` is sent as a string.
+//
+// Exported struct fields become record fields named by their `proto` tag,
+// or by the Go field name without one:
+//
+//	ID    int64  `proto:"id"`
+//	Email string `proto:"email,omitempty"` // omitted when empty
+//	Cache []byte `proto:"-"`               // never sent
+//
+// Unmarshal fills a struct from a *Record by field name, or from a
+// *DataInput by position. Record fields the struct does not have, struct
+// fields missing from the record unless they are omitempty, and DataInputs
+// of the wrong length are errors. The record name is not checked.
+//
+// A value that refers to itself through pointers or slices cannot be
+// marshaled; Marshal reports the cycle instead of following it forever.
+//
+// The conversion for each Go type is worked out once and cached, so codecs
+// must be registered before the first Marshal of a type that uses them.
+
+// Marshal returns the encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	if v == nil {
+		return Encode(nil)
+	}
+	rv := reflect.ValueOf(v)
+	p := planFor(rv.Type())
+	if p.err != nil {
+		return nil, fmt.Errorf("protocol: marshal %v: %w", rv.Type(), p.err)
+	}
+	value, err := p.marshal(&marshalState{}, rv)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: marshal %v: %w", rv.Type(), err)
+	}
+	return Encode(value)
+}
+
+// Unmarshal decodes data and stores the result in the value pointed to by
+// v. Decoding failures are returned as a *DecodeError.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("protocol: Unmarshal needs a non-nil pointer, got %T", v)
+	}
+	decoded, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	dst := rv.Elem()
+	p := planFor(dst.Type())
+	if p.err != nil {
+		return fmt.Errorf("protocol: unmarshal %v: %w", dst.Type(), p.err)
+	}
+	if err := p.set(decoded, dst); err != nil {
+		return fmt.Errorf("protocol: unmarshal %v: %w", dst.Type(), err)
+	}
+	return nil
+}
+
+// typePlan converts between one Go type and protocol values
+type typePlan struct {
+	typ       reflect.Type
+	err       error // set if the type cannot be marshaled
+	marshal   func(st *marshalState, v reflect.Value) (interface{}, error)
+	unmarshal func(src interface{}, dst reflect.Value) error
+}
+
+// startDetectingCycles is the number of pointers and slices Marshal follows
+// into each other before it checks them for cycles, which would otherwise
+// recurse until the stack overflows. As in encoding/json, shallow values
+// are not slowed down by the check.
+const startDetectingCycles = 1000
+
+// marshalState is the state of one Marshal call
+type marshalState struct {
+	level int                  // pointers and slices being followed
+	seen  map[interface{}]bool // those being followed, past startDetectingCycles
+}
+
+// cycleError reports a value that refers to itself. It is returned without
+// the field and element positions of every level of the cycle.
+type cycleError struct {
+	typ reflect.Type
+}
+
+func (e *cycleError) Error() string {
+	return fmt.Sprintf("encountered a cycle via %v", e.typ)
+}
+
+// enter records that the pointer or slice v is being followed, failing if
+// it already is. The returned key is passed to leave.
+func (st *marshalState) enter(v reflect.Value) (interface{}, error) {
+	st.level++
+	if st.level <= startDetectingCycles {
+		return nil, nil
+	}
+	if st.seen == nil {
+		st.seen = make(map[interface{}]bool)
+	}
+	// A slice is the same only with the same length, since a shorter one
+	// may share the array
+	var key interface{} = v.Pointer()
+	if v.Kind() == reflect.Slice {
+		key = [2]uintptr{v.Pointer(), uintptr(v.Len())}
+	}
+	if st.seen[key] {
+		st.level--
+		return nil, &cycleError{v.Type()}
+	}
+	st.seen[key] = true
+	return key, nil
+}
+
+// leave undoes enter
+func (st *marshalState) leave(key interface{}) {
+	st.level--
+	if key != nil {
+		delete(st.seen, key)
+	}
+}
+
+// fieldPlan describes one struct field
+type fieldPlan struct {
+	name      string
+	index     int
+	omitEmpty bool
+	plan      *typePlan
+}
+
+var (
+	plans  sync.Map   // reflect.Type -> *typePlan
+	planMu sync.Mutex // serialises building plans
+)
+
+// nativeTypes are passed to the encoder unchanged
+var nativeTypes = map[reflect.Type]bool{
+	reflect.TypeOf(time.Time{}):      true,
+	reflect.TypeOf(time.Duration(0)): true,
+	reflect.TypeOf(UUID{}):           true,
+	reflect.TypeOf(Decimal{}):        true,
+	reflect.TypeOf(&big.Int{}):       true,
+	reflect.TypeOf(Enum{}):           true,
+	reflect.TypeOf(netip.Addr{}):     true,
+	reflect.TypeOf(netip.Prefix{}):   true,
+	reflect.TypeOf(TypedNull{}):      true,
+	reflect.TypeOf(RawExtension{}):   true,
+	reflect.TypeOf(&DataInput{}):     true,
+	reflect.TypeOf(&Map{}):           true,
+	reflect.TypeOf(&Record{}):        true,
+	reflect.TypeOf([]byte{}):         true,
+	reflect.TypeOf([]int32{}):        true,
+	reflect.TypeOf([]int64{}):        true,
+	reflect.TypeOf([]float64{}):      true,
+	reflect.TypeOf([]bool{}):         true,
+}
+
+// nullWhenZero are native types whose zero value cannot be encoded; it is
+// sent as null instead
+var nullWhenZero = map[reflect.Type]bool{
+	reflect.TypeOf(Enum{}):         true,
+	reflect.TypeOf(netip.Addr{}):   true,
+	reflect.TypeOf(netip.Prefix{}): true,
+}
+
+// planFor returns the cached plan for t, building it on first use
+func planFor(t reflect.Type) *typePlan {
+	if p, ok := plans.Load(t); ok {
+		return p.(*typePlan)
+	}
+	planMu.Lock()
+	defer planMu.Unlock()
+	building := make(map[reflect.Type]*typePlan)
+	p := buildPlan(t, building)
+	// A plan that refers to one still being built copied its error before
+	// the error was known, so if any plan failed none is published; the
+	// next use builds them again
+	for _, bp := range building {
+		if bp.err != nil {
+			return p
+		}
+	}
+	// Publish only complete plans, including those of recursive types
+	for bt, bp := range building {
+		plans.Store(bt, bp)
+	}
+	return p
+}
+
+// buildPlan builds the plan for t. Plans under construction are kept in
+// building so that recursive types refer to themselves.
+func buildPlan(t reflect.Type, building map[reflect.Type]*typePlan) *typePlan {
+	if p, ok := plans.Load(t); ok {
+		return p.(*typePlan)
+	}
+	if p, ok := building[t]; ok {
+		return p
+	}
+	p := &typePlan{typ: t}
+	building[t] = p
+
+	if nativeTypes[t] || (t.Kind() != reflect.Interface && DefaultRegistry.codecFor(reflect.Zero(t).Interface()) != nil) {
+		p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+			return v.Interface(), nil
+		}
+		// Nil pointers such as a nil *Map are nulls, like other nil pointers
+		if nullWhenZero[t] || t.Kind() == reflect.Pointer {
+			p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+				if v.IsZero() {
+					return nil, nil
+				}
+				return v.Interface(), nil
+			}
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			return mismatch(src, t)
+		}
+		return p
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+			return v.String(), nil
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			switch s := src.(type) {
+			case string:
+				dst.SetString(s)
+				return nil
+			case Enum:
+				dst.SetString(s.Symbol())
+				return nil
+			}
+			return mismatch(src, t)
+		}
+
+	case reflect.Bool:
+		p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+			return v.Bool(), nil
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			b, ok := src.(bool)
+			if !ok {
+				return mismatch(src, t)
+			}
+			dst.SetBool(b)
+			return nil
+		}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Int64:
+		if t.Bits() <= 32 && t.Kind() != reflect.Int {
+			p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+				return int32(v.Int()), nil
+			}
+		} else {
+			p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+				return v.Int(), nil
+			}
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			var n int64
+			switch i := src.(type) {
+			case int32:
+				n = int64(i)
+			case int64:
+				n = i
+			default:
+				return mismatch(src, t)
+			}
+			if dst.OverflowInt(n) {
+				return fmt.Errorf("%d overflows %v", n, t)
+			}
+			dst.SetInt(n)
+			return nil
+		}
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+			return v.Uint(), nil
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			n, ok := src.(uint64)
+			if !ok {
+				return mismatch(src, t)
+			}
+			if dst.OverflowUint(n) {
+				return fmt.Errorf("%d overflows %v", n, t)
+			}
+			dst.SetUint(n)
+			return nil
+		}
+
+	case reflect.Float32:
+		p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+			return float32(v.Float()), nil
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			f, ok := src.(float32)
+			if !ok {
+				return mismatch(src, t)
+			}
+			dst.SetFloat(float64(f))
+			return nil
+		}
+
+	case reflect.Float64:
+		p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+			return v.Float(), nil
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			switch f := src.(type) {
+			case float64:
+				dst.SetFloat(f)
+				return nil
+			case float32:
+				dst.SetFloat(float64(f))
+				return nil
+			}
+			return mismatch(src, t)
+		}
+
+	case reflect.Slice:
+		buildSlicePlan(p, building)
+
+	case reflect.Pointer:
+		elem := buildPlan(t.Elem(), building)
+		p.err = elem.err
+		p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+			if v.IsNil() {
+				return nil, nil
+			}
+			key, err := st.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer st.leave(key)
+			return elem.marshal(st, v.Elem())
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			ptr := reflect.New(t.Elem())
+			if err := elem.set(src, ptr.Elem()); err != nil {
+				return err
+			}
+			dst.Set(ptr)
+			return nil
+		}
+
+	case reflect.Struct:
+		buildStructPlan(p, building)
+
+	case reflect.Interface:
+		p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+			if v.IsNil() {
+				return nil, nil
+			}
+			elem := planFor(v.Elem().Type())
+			if elem.err != nil {
+				return nil, elem.err
+			}
+			return elem.marshal(st, v.Elem())
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			return mismatch(src, t)
+		}
+
+	default:
+		p.err = fmt.Errorf("unsupported type %v", t)
+	}
+	return p
+}
+
+// buildSlicePlan fills in the plan of a slice type other than []byte and
+// the packed array types
+func buildSlicePlan(p *typePlan, building map[reflect.Type]*typePlan) {
+	t := p.typ
+	if t.Elem().Kind() == reflect.Uint8 {
+		// Named byte slices are sent as bytes
+		p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+			return v.Bytes(), nil
+		}
+		p.unmarshal = func(src interface{}, dst reflect.Value) error {
+			b, ok := src.([]byte)
+			if !ok {
+				return mismatch(src, t)
+			}
+			dst.Set(reflect.ValueOf(b).Convert(t))
+			return nil
+		}
+		return
+	}
+	elem := buildPlan(t.Elem(), building)
+	p.err = elem.err
+	p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+		if v.IsNil() {
+			return nil, nil
+		}
+		key, err := st.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer st.leave(key)
+		elements := make([]interface{}, v.Len())
+		for i := range elements {
+			e, err := elem.marshal(st, v.Index(i))
+			if _, cycle := err.(*cycleError); cycle {
+				return nil, err
+			}
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = e
+		}
+		return &DataInput{elements: elements}, nil
+	}
+	p.unmarshal = func(src interface{}, dst reflect.Value) error {
+		d, ok := src.(*DataInput)
+		if !ok {
+			return mismatch(src, t)
+		}
+		s := reflect.MakeSlice(t, len(d.elements), len(d.elements))
+		for i, e := range d.elements {
+			if err := elem.set(e, s.Index(i)); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		dst.Set(s)
+		return nil
+	}
+}
+
+// buildStructPlan fills in the plan of a struct type from its fields and
+// their proto tags
+func buildStructPlan(p *typePlan, building map[reflect.Type]*typePlan) {
+	t := p.typ
+	var fields []fieldPlan
+	byName := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(sf.Tag.Get("proto"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if existing, ok := byName[name]; ok {
+			p.err = fmt.Errorf("fields %s and %s are both named %q", t.Field(fields[existing].index).Name, sf.Name, name)
+			return
+		}
+		fp := fieldPlan{
+			name:      name,
+			index:     i,
+			omitEmpty: opts == "omitempty",
+			plan:      buildPlan(sf.Type, building),
+		}
+		if fp.plan.err != nil {
+			p.err = fmt.Errorf("field %s: %w", sf.Name, fp.plan.err)
+			return
+		}
+		byName[name] = len(fields)
+		fields = append(fields, fp)
+	}
+
+	name := t.Name()
+	p.marshal = func(st *marshalState, v reflect.Value) (interface{}, error) {
+		r := &Record{name: name, fields: make([]Field, 0, len(fields))}
+		for _, f := range fields {
+			fv := v.Field(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			value, err := f.plan.marshal(st, fv)
+			if _, cycle := err.(*cycleError); cycle {
+				return nil, err
+			}
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			r.add(f.name, value)
+		}
+		return r, nil
+	}
+	p.unmarshal = func(src interface{}, dst reflect.Value) error {
+		dst.Set(reflect.Zero(t))
+		switch s := src.(type) {
+		case *Record:
+			seen := make([]bool, len(fields))
+			for _, rf := range s.fields {
+				i, ok := byName[rf.Name]
+				if !ok {
+					return fmt.Errorf("record field %q has no matching field in %v", rf.Name, t)
+				}
+				f := fields[i]
+				if err := f.plan.set(rf.Value, dst.Field(f.index)); err != nil {
+					return fmt.Errorf("field %s: %w", f.name, err)
+				}
+				seen[i] = true
+			}
+			for i, f := range fields {
+				if !seen[i] && !f.omitEmpty {
+					return fmt.Errorf("field %s missing from record %s", f.name, s.name)
+				}
+			}
+			return nil
+		case *DataInput:
+			if len(s.elements) != len(fields) {
+				return fmt.Errorf("DataInput has %d elements, %v has %d fields", len(s.elements), t, len(fields))
+			}
+			for i, f := range fields {
+				if err := f.plan.set(s.elements[i], dst.Field(f.index)); err != nil {
+					return fmt.Errorf("field %s (element %d): %w", f.name, i, err)
+				}
+			}
+			return nil
+		}
+		return mismatch(src, t)
+	}
+}
+
+// set stores the decoded value src in dst. Values that already have the
+// type of dst are stored as they are, and nulls store the zero value.
+func (p *typePlan) set(src interface{}, dst reflect.Value) error {
+	if src != nil && reflect.TypeOf(src).AssignableTo(p.typ) {
+		dst.Set(reflect.ValueOf(src))
+		return nil
+	}
+	switch src.(type) {
+	case nil, TypedNull:
+		dst.Set(reflect.Zero(p.typ))
+		return nil
+	}
+	return p.unmarshal(src, dst)
+}
+
+// mismatch reports a decoded value that cannot be stored in a Go type
+func mismatch(src interface{}, t reflect.Type) error {
+	return fmt.Errorf("cannot unmarshal %s into %v", goTypeName(src), t)
+}
+
+// goTypeName names the Go type of a decoded value for error messages
+func goTypeName(v interface{}) string {
+	switch v := v.(type) {
+	case *Record:
+		return "Record(" + v.name + ")"
+	case *DataInput:
+		return "DataInput"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,284 @@
+package protocol
+
+import (
+	"errors"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type marshalStatus string
+
+type marshalAddress struct {
+	Street string `proto:"street"`
+	Zip    int32  `proto:"zip,omitempty"`
+}
+
+type marshalUser struct {
+	ID       int64            `proto:"id"`
+	Name     string           `proto:"name"`
+	Status   marshalStatus    `proto:"status"`
+	Age      uint8            `proto:"age"`
+	Score    float64          `proto:"score"`
+	Admin    bool             `proto:"admin"`
+	Email    string           `proto:"email,omitempty"`
+	Tags     []string         `proto:"tags"`
+	Scores   []int32          `proto:"scores"`
+	Home     *marshalAddress  `proto:"home"`
+	Work     *marshalAddress  `proto:"work,omitempty"`
+	Previous []marshalAddress `proto:"previous"`
+	Created  time.Time        `proto:"created"`
+	Client   netip.Addr       `proto:"client"`
+	Extra    interface{}      `proto:"extra"`
+	Cache    []byte           `proto:"-"`
+	internal int
+}
+
+// TestMarshalRoundTrip tests Go structs through records and back
+func TestMarshalRoundTrip(t *testing.T) {
+	in := marshalUser{
+		ID:       42,
+		Name:     "alice",
+		Status:   "active",
+		Age:      31,
+		Score:    9.5,
+		Admin:    true,
+		Tags:     []string{"a", "b"},
+		Scores:   []int32{1, 2, 3},
+		Home:     &marshalAddress{Street: "Main St", Zip: 12345},
+		Previous: []marshalAddress{{Street: "Old Rd"}},
+		Created:  time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC),
+		Client:   netip.MustParseAddr("192.0.2.1"),
+		Extra:    NewDataInput("x", int32(1)),
+		Cache:    []byte("not sent"),
+		internal: 7,
+	}
+	data, err := Marshal(&in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	r, ok := decoded.(*Record)
+	if !ok || r.Name() != "marshalUser" {
+		t.Fatalf("decoded %s, want Record(marshalUser)", formatDataInput(decoded))
+	}
+	for _, name := range []string{"email", "work", "Cache", "internal"} {
+		if _, ok := r.Get(name); ok {
+			t.Errorf("field %q was sent", name)
+		}
+	}
+	if v, _ := r.Get("status"); v != "active" {
+		t.Errorf(`Get("status") = %v, want "active"`, v)
+	}
+	if v, _ := r.Get("home"); !compareDataInput(v, NewRecord("marshalAddress", Field{"street", "Main St"}, Field{"zip", int32(12345)})) {
+		t.Errorf(`Get("home") = %s`, formatDataInput(v))
+	}
+
+	var out marshalUser
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	in.Cache, in.internal = nil, 0
+	if !compareDataInput(in.Extra, out.Extra) {
+		t.Errorf("Extra = %s, want %s", formatDataInput(out.Extra), formatDataInput(in.Extra))
+	}
+	in.Extra, out.Extra = nil, nil
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Unmarshal = %+v, want %+v", out, in)
+	}
+}
+
+// TestMarshalValues tests top-level values other than structs
+func TestMarshalValues(t *testing.T) {
+	data, err := Marshal([]*marshalAddress{{Street: "a"}, nil})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := NewDataInput(NewRecord("marshalAddress", Field{"street", "a"}), nil)
+	if decoded, _ := Decode(data); !compareDataInput(want, decoded) {
+		t.Errorf("Marshal = %s, want %s", formatDataInput(decoded), formatDataInput(want))
+	}
+	var addrs []*marshalAddress
+	if err := Unmarshal(data, &addrs); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(addrs) != 2 || addrs[0].Street != "a" || addrs[1] != nil {
+		t.Errorf("Unmarshal = %v", addrs)
+	}
+
+	// Rows built by position fill a struct in field order
+	row, _ := Encode(NewDataInput("Main St", int32(7)))
+	var addr marshalAddress
+	if err := Unmarshal(row, &addr); err != nil || addr != (marshalAddress{"Main St", 7}) {
+		t.Errorf("Unmarshal(DataInput) = %+v, %v", addr, err)
+	}
+
+	var n int16
+	if err := Unmarshal(mustMarshal(t, int16(-300)), &n); err != nil || n != -300 {
+		t.Errorf("Unmarshal(int16) = %d, %v", n, err)
+	}
+	var s *string
+	if err := Unmarshal(mustMarshal(t, nil), &s); err != nil || s != nil {
+		t.Errorf("Unmarshal(nil) = %v, %v", s, err)
+	}
+}
+
+// TestMarshalRecursive tests self-referencing types
+func TestMarshalRecursive(t *testing.T) {
+	type node struct {
+		Value    int32   `proto:"value"`
+		Children []*node `proto:"children,omitempty"`
+	}
+	in := &node{Value: 1, Children: []*node{{Value: 2}, {Value: 3, Children: []*node{{Value: 4}}}}}
+	var out *node
+	if err := Unmarshal(mustMarshal(t, in), &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Unmarshal = %+v, want %+v", out, in)
+	}
+
+	// Values that refer to themselves are errors rather than a stack
+	// overflow, through pointers, slices or interfaces
+	type link struct {
+		Next *link
+	}
+	self := &link{}
+	self.Next = self
+	parent := &node{Value: 1}
+	parent.Children = []*node{{Value: 2}, parent}
+	list := []interface{}{"a", nil}
+	list[1] = list
+	for _, v := range []interface{}{self, parent, list} {
+		if _, err := Marshal(v); err == nil || !strings.Contains(err.Error(), "encountered a cycle") {
+			t.Errorf("Marshal(%T) error = %v, want a cycle", v, err)
+		}
+	}
+
+	// Deep values without a cycle are not mistaken for one
+	deep := &link{}
+	for i := 0; i < 2*startDetectingCycles; i++ {
+		deep = &link{Next: deep}
+	}
+	if _, err := Marshal(deep); err != nil {
+		t.Errorf("Marshal(deep) failed: %v", err)
+	}
+}
+
+// TestMarshalErrors tests unsupported types and mismatched messages
+func TestMarshalErrors(t *testing.T) {
+	if _, err := Marshal(map[string]int{}); err == nil || !strings.Contains(err.Error(), "unsupported type") {
+		t.Errorf("Marshal(map) error = %v", err)
+	}
+	type duplicate struct {
+		A int32 `proto:"x"`
+		B int32 `proto:"x"`
+	}
+	if _, err := Marshal(duplicate{}); err == nil || !strings.Contains(err.Error(), `both named "x"`) {
+		t.Errorf("Marshal(duplicate) error = %v", err)
+	}
+
+	// A recursive type that fails must not leave plans built against it
+	// behind, such as that of *unsupported while unsupported was unfinished
+	type unsupported struct {
+		Next *unsupported
+		C    chan int
+	}
+	if _, err := Marshal(unsupported{}); err == nil {
+		t.Error("Marshal(unsupported) succeeded")
+	}
+	if _, err := Marshal(&unsupported{}); err == nil || !strings.Contains(err.Error(), "unsupported type chan int") {
+		t.Errorf("Marshal(&unsupported) error = %v", err)
+	}
+
+	// Nil pointers of native types are nulls
+	var containers struct {
+		D *DataInput
+		M *Map
+		R *Record
+		B *big.Int
+	}
+	data, err := Marshal(containers)
+	if err != nil {
+		t.Fatalf("Marshal(nil containers) failed: %v", err)
+	}
+	if err := Unmarshal(data, &containers); err != nil || containers.M != nil {
+		t.Errorf("Unmarshal(nil containers) = %+v, %v", containers, err)
+	}
+
+	var addr marshalAddress
+	if err := Unmarshal([]byte{0x40}, &addr); !errors.Is(err, ErrUnknownTag) {
+		t.Errorf("Unmarshal(garbage) error = %v, want %v", err, ErrUnknownTag)
+	}
+	if err := Unmarshal(mustMarshal(t, "x"), addr); err == nil {
+		t.Error("Unmarshal into non-pointer succeeded")
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"Unknown field", NewRecord("a", Field{"street", "s"}, Field{"city", "c"}), `record field "city" has no matching field`},
+		{"Missing field", NewRecord("a", Field{"zip", int32(1)}), "field street missing from record a"},
+		{"Wrong type", NewRecord("a", Field{"street", int32(1)}), "field street: cannot unmarshal int32 into string"},
+		{"Overflow", NewRecord("a", Field{"street", "s"}, Field{"zip", int64(1 << 40)}), "field zip: 1099511627776 overflows int32"},
+		{"Short row", NewDataInput("s"), "DataInput has 1 elements, protocol.marshalAddress has 2 fields"},
+		{"Row element", NewDataInput("s", "t"), "field zip (element 1): cannot unmarshal string into int32"},
+		{"Not a struct", "s", "cannot unmarshal string into protocol.marshalAddress"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Encode(tt.value)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			err = Unmarshal(data, &addr)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Unmarshal error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	return data
+}
+
+// BenchmarkMarshal measures the cached reflection path
+func BenchmarkMarshal(b *testing.B) {
+	in := marshalUser{ID: 1, Name: "alice", Status: "active", Tags: []string{"a"}, Home: &marshalAddress{Street: "s"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(&in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkUnmarshal measures the cached reflection path
+func BenchmarkUnmarshal(b *testing.B) {
+	data, err := Marshal(marshalUser{ID: 1, Name: "alice", Status: "active", Tags: []string{"a"}, Home: &marshalAddress{Street: "s"}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var out marshalUser
+		if err := Unmarshal(data, &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package protocol
+
+import "errors"
+
+// ErrLimitExceeded is the DecodeError kind reported when a message exceeds
+// one of the DecoderOptions limits.
+var ErrLimitExceeded = errors.New("decoder limit exceeded")
+
+// DefaultMaxMessageBytes matches encoding.max_message_size in the
+// protocol-config ConfigMap (k8s-deployment.yaml).
+const DefaultMaxMessageBytes = 10 << 20
+
+// DecoderOptions bounds the resources a single decode may consume.
+// A zero value for any limit disables it, so a DecoderOptions literal
+// decodes without limits; set the other options on DefaultDecoderOptions()
+// instead:
+//
+//	opts := DefaultDecoderOptions()
+//	opts.ZeroCopyBytes = true
+//	v, err := DecodeWithOptions(data, opts)
+type DecoderOptions struct {
+	// MaxDepth is the maximum nesting depth of DataInput values; a
+	// top-level DataInput has depth 1.
+	MaxDepth int
+	// MaxElements is the maximum element count of a single DataInput.
+	MaxElements int
+	// MaxTotalElements is the maximum number of container elements in the
+	// whole message.
+	MaxTotalElements int
+	// MaxStringBytes is the maximum length of a single string or byte
+	// slice.
+	MaxStringBytes int
+	// MaxMessageBytes is the maximum size of the encoded message.
+	MaxMessageBytes int
+
+	// ZeroCopyBytes makes decoded []byte values sub-slices of the input
+	// instead of copies. The input must not be modified while they are in
+	// use. Like the options after it, it is off in DefaultDecoderOptions;
+	// set it on that result to keep the limits.
+	ZeroCopyBytes bool
+	// ZeroCopyStrings makes decoded strings share the memory of the input,
+	// through ZeroCopyString, instead of being copies. The input must not
+	// be modified while they are in use.
+	ZeroCopyStrings bool
+	// StrictMaps rejects maps that repeat a key. Otherwise the last value
+	// for a key wins and keeps the position of the first occurrence.
+	StrictMaps bool
+	// Registry supplies the codecs for extension tags. Nil means
+	// DefaultRegistry.
+	Registry *Registry
+	// Enums lists the enum tables declared by the schema rather than in
+	// the message. It must match EncoderOptions.Enums of the sender.
+	Enums []*EnumType
+	// EnumsAsStrings decodes enum values to their symbol strings instead
+	// of Enum values.
+	EnumsAsStrings bool
+}
+
+// EncoderOptions configures EncodeWithOptions.
+type EncoderOptions struct {
+	// Registry supplies the codecs for application-defined types. Nil means
+	// DefaultRegistry.
+	Registry *Registry
+	// Enums lists the enum tables declared by the schema. Values of these
+	// enums are sent without their symbol table.
+	Enums []*EnumType
+}
+
+// DefaultDecoderOptions returns the limits used by Decode. They are sized
+// for untrusted input arriving on the protocol port.
+func DefaultDecoderOptions() DecoderOptions {
+	return DecoderOptions{
+		MaxDepth:         64,
+		MaxElements:      1 << 20,
+		MaxTotalElements: 1 << 22,
+		MaxStringBytes:   DefaultMaxMessageBytes,
+		MaxMessageBytes:  DefaultMaxMessageBytes,
+	}
+}
+
+// exceeds reports whether n is over limit, treating 0 as unlimited
+func exceeds(n uint64, limit int) bool {
+	return limit > 0 && n > uint64(limit)
+}
@@ -0,0 +1,1100 @@
+// Package protocol implements a compact binary encoding for heterogeneous,
+// nested data structures used by the database integrations.
+//
+// Every value is written as a one byte type tag followed by its payload:
+//
+//	[TypeString][Length as varint][UTF-8 bytes]
+//	[TypeInt32][4 bytes little-endian]
+//	[TypeVarInt32][zigzag varint] or [TypeFixIntMin+16+value] for -16..127
+//	[TypeDataInput][Count as varint][Elements...]
+//	[TypeFloat64][8 bytes IEEE 754 little-endian]
+//	[TypeFloat32][4 bytes IEEE 754 little-endian]
+//	[TypeInt64][zigzag varint]
+//	[TypeUint64][varint]
+//	[TypeFalse] / [TypeTrue]
+//	[TypeBytes][Length as varint][raw bytes]
+//	[TypeTime][Unix seconds as zigzag varint][Nanoseconds as varint]
+//	[TypeTimeTZ][Unix seconds as zigzag varint][Nanoseconds as varint][Zone offset in minutes as zigzag varint]
+//	[TypeDuration][Nanoseconds as zigzag varint]
+//	[TypeMap][Count as varint][Key1][Value1][Key2][Value2]...
+//	[TypeUUID][16 bytes]
+//	[TypeDecimal][Scale as zigzag varint][Unscaled integer]
+//	[TypeBigInt][(Magnitude length << 1) | sign as varint][Magnitude big-endian]
+//	[TypeNull]
+//	[TypeTypedNull][Type tag of the column]
+//	[TypeRecord][Name][Field count as varint][Field name][Value]...
+//	[TypeEnum][Symbol table][Index as varint]
+//	[TypeIPAddr][Address length][Address bytes]
+//	[TypeIPPrefix][Address length][Address bytes][Prefix length]
+//	[TypeInt32Array|TypeInt64Array|TypeFloat64Array|TypeBoolArray][Count as varint][Packed payload]
+//	[0xF0-0xFF][Length as varint][Payload]
+//
+// Tags 0xF0-0xFF are reserved for application-defined types registered
+// through a TypeCodec.
+//
+// Encode and Decode are the entry points; DataInput is the container type
+// used for positional lists of values.
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Type tags identify the encoded type of each element.
+const (
+	TypeString    byte = 0x01
+	TypeInt32     byte = 0x02
+	TypeDataInput byte = 0x03
+	TypeFloat64   byte = 0x04
+	TypeFloat32   byte = 0x05
+	TypeInt64     byte = 0x06
+	TypeUint64    byte = 0x07
+	TypeFalse     byte = 0x08
+	TypeTrue      byte = 0x09
+	TypeBytes     byte = 0x0A
+	TypeTime      byte = 0x0B
+	TypeTimeTZ    byte = 0x0C
+	TypeDuration  byte = 0x0D
+	TypeMap       byte = 0x0E
+	TypeUUID      byte = 0x0F
+	TypeDecimal   byte = 0x10
+	TypeBigInt    byte = 0x11
+	TypeTypedNull byte = 0x12
+	TypeNull      byte = 0x00
+
+	// Packed homogeneous arrays, see arrays.go
+	TypeInt32Array   byte = 0x13
+	TypeInt64Array   byte = 0x14
+	TypeFloat64Array byte = 0x15
+	TypeBoolArray    byte = 0x16
+
+	// Compact int32 forms; the encoder picks the smallest of these and
+	// TypeInt32
+	TypeVarInt32  byte = 0x17 // zigzag varint
+	TypeFixIntMin byte = 0x60 // -16 embedded in the tag
+	TypeFixIntMax byte = 0xEF // 127 embedded in the tag
+
+	// Named records, see record.go
+	TypeRecord byte = 0x18
+
+	// Values of a declared symbol table, see enum.go
+	TypeEnum byte = 0x19
+
+	// IP addresses and networks, see ip.go
+	TypeIPAddr   byte = 0x1A
+	TypeIPPrefix byte = 0x1B
+)
+
+// Range of int32 values carried in a single fixint tag byte
+const (
+	fixIntMin = -16
+	fixIntMax = 127
+)
+
+// TypedNull is a null that records the type of the value it stands for,
+// such as a NULL read from an INT column. A plain nil remains an untyped
+// null.
+type TypedNull struct {
+	Type byte // type tag of the missing value, e.g. TypeInt32
+}
+
+// typeNames holds the name of every built-in value type tag
+var typeNames = map[byte]string{
+	TypeString:    "string",
+	TypeInt32:     "int32",
+	TypeDataInput: "DataInput",
+	TypeFloat64:   "float64",
+	TypeFloat32:   "float32",
+	TypeInt64:     "int64",
+	TypeUint64:    "uint64",
+	TypeFalse:     "bool",
+	TypeTrue:      "bool",
+	TypeBytes:     "bytes",
+	TypeTime:      "time",
+	TypeTimeTZ:    "timetz",
+	TypeDuration:  "duration",
+	TypeMap:       "Map",
+	TypeUUID:      "UUID",
+	TypeDecimal:   "Decimal",
+	TypeBigInt:    "BigInt",
+	TypeVarInt32:  "int32",
+	TypeRecord:    "Record",
+	TypeEnum:      "Enum",
+	TypeIPAddr:    "netip.Addr",
+	TypeIPPrefix:  "netip.Prefix",
+
+	TypeInt32Array:   "[]int32",
+	TypeInt64Array:   "[]int64",
+	TypeFloat64Array: "[]float64",
+	TypeBoolArray:    "[]bool",
+}
+
+// isValueTag reports whether a typed null may stand for the tag: any
+// built-in value type or extension tag, but not a null itself
+func isValueTag(tag byte) bool {
+	_, ok := typeNames[tag]
+	return ok || tag >= TypeExtensionMin
+}
+
+// typeName returns a readable name for a type tag
+func typeName(tag byte) string {
+	if name, ok := typeNames[tag]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", tag)
+}
+
+// DataInput is an ordered list of encodable values. Elements may be
+// strings, []byte, int32s, int64s, uint64s, float32s, float64s, bools,
+// time.Time, time.Duration, UUID, Decimal, *big.Int, Enum, netip.Addr,
+// netip.Prefix, nil, TypedNull, packed []int32, []int64, []float64 and []bool
+// arrays, nested *DataInput or *Map values. Go int and uint are encoded as
+// int64 and uint64.
+type DataInput struct {
+	elements []interface{}
+}
+
+// NewDataInput creates a DataInput holding the given elements.
+func NewDataInput(elements ...interface{}) *DataInput {
+	return &DataInput{elements: elements}
+}
+
+// Elements returns the elements of the DataInput.
+func (d *DataInput) Elements() []interface{} {
+	return d.elements
+}
+
+// Len returns the number of elements in the DataInput.
+func (d *DataInput) Len() int {
+	return len(d.elements)
+}
+
+// Append adds elements to the end of the DataInput.
+func (d *DataInput) Append(elements ...interface{}) {
+	d.elements = append(d.elements, elements...)
+}
+
+func encodeVarint(n uint64) []byte {
+	var buf [10]byte
+	var i int
+	for n >= 0x80 {
+		buf[i] = byte(n) | 0x80
+		n >>= 7
+		i++
+	}
+	buf[i] = byte(n)
+	return buf[:i+1]
+}
+
+// zigzagEncode maps signed integers to unsigned ones so that values of small
+// magnitude, positive or negative, produce short varints:
+// 0 -> 0, -1 -> 1, 1 -> 2, -2 -> 3, ...
+func zigzagEncode(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
+
+// zigzagDecode reverses zigzagEncode
+func zigzagDecode(n uint64) int64 {
+	return int64(n>>1) ^ -int64(n&1)
+}
+
+// decodeVarint returns the value and the number of bytes consumed, or
+// ErrTruncated / ErrVarintOverflow.
+func decodeVarint(data []byte) (uint64, int, error) {
+	var n uint64
+	var shift uint
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		if i == 9 && b > 1 {
+			return 0, 0, ErrVarintOverflow
+		}
+		n |= uint64(b&0x7F) << shift
+		if b < 0x80 {
+			return n, i + 1, nil
+		}
+		shift += 7
+	}
+	return 0, 0, ErrTruncated
+}
+
+// Encode converts a value to its binary representation.
+// Unlike encode, it reports any element that cannot be encoded instead of
+// returning a truncated message.
+// Time Complexity: O(n) where n is the total number of elements including nested ones
+// Space Complexity: O(m) where m is the total size of all data
+func Encode(v interface{}) ([]byte, error) {
+	return EncodeWithOptions(v, EncoderOptions{})
+}
+
+// EncodeWithOptions is like Encode but uses the given options.
+func EncodeWithOptions(v interface{}, opts EncoderOptions) ([]byte, error) {
+	buf := &buffer{data: make([]byte, 0, 1024), reg: opts.Registry} // Pre-allocate for efficiency
+	buf.enums = append(buf.enums, opts.Enums...)
+	if err := encodeElement(buf, v); err != nil {
+		return nil, err
+	}
+	return buf.data, nil
+}
+
+// encode converts DataInput to a binary string.
+// It returns an empty string if the value cannot be encoded; use Encode to
+// get the error.
+func encode(toSend interface{}) string {
+	data, err := Encode(toSend)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// encodeElement recursively encodes a single element
+// Time Complexity: O(1) for primitives, O(k) for strings where k is string length,
+//                  O(n) for DataInput where n is number of elements
+func encodeElement(buf *buffer, elem interface{}) error {
+	switch v := elem.(type) {
+	case string:
+		// Encode string: [TypeString][Length as varint][UTF-8 bytes]
+		if buf.direct(len(v)) {
+			if err := buf.flushHeader(TypeString, len(v)); err != nil {
+				return err
+			}
+			_, err := io.WriteString(buf.w, v)
+			return err
+		}
+		buf.data = AppendString(buf.data, v)
+		
+	case []byte:
+		// Encode bytes: [TypeBytes][Length as varint][raw bytes]
+		if buf.direct(len(v)) {
+			if err := buf.flushHeader(TypeBytes, len(v)); err != nil {
+				return err
+			}
+			_, err := buf.w.Write(v)
+			return err
+		}
+		buf.data = AppendBytes(buf.data, v)
+		
+	case []int32:
+		buf.data = AppendInt32Array(buf.data, v)
+		
+	case []int64:
+		buf.data = AppendInt64Array(buf.data, v)
+		
+	case []float64:
+		buf.data = AppendFloat64Array(buf.data, v)
+		
+	case []bool:
+		buf.data = AppendBoolArray(buf.data, v)
+		
+	case int32:
+		buf.data = AppendInt32(buf.data, v)
+		
+	case int64:
+		// Encode int64: [TypeInt64][zigzag varint]
+		buf.data = AppendInt64(buf.data, v)
+		
+	case int:
+		buf.data = AppendInt64(buf.data, int64(v))
+		
+	case uint64:
+		// Encode uint64: [TypeUint64][varint]
+		buf.data = AppendUint64(buf.data, v)
+		
+	case uint:
+		buf.data = AppendUint64(buf.data, uint64(v))
+		
+	case bool:
+		// Encode bool: the value is carried by the tag itself, one byte total
+		buf.data = AppendBool(buf.data, v)
+		
+	case time.Time:
+		// Encode time: [TypeTime|TypeTimeTZ][seconds][nanos][zone offset]
+		buf.data = AppendTime(buf.data, v)
+		
+	case time.Duration:
+		// Encode duration: [TypeDuration][nanoseconds as zigzag varint]
+		buf.data = AppendDuration(buf.data, v)
+		
+	case UUID:
+		// Encode UUID: [TypeUUID][16 raw bytes]
+		buf.WriteByte(TypeUUID)
+		buf.Write(v[:])
+		
+	case Decimal:
+		// Encode Decimal: [TypeDecimal][scale as zigzag varint][unscaled]
+		if v.Scale < -MaxDecimalScale || v.Scale > MaxDecimalScale {
+			return fmt.Errorf("decimal scale %d exceeds %d", v.Scale, MaxDecimalScale)
+		}
+		buf.WriteByte(TypeDecimal)
+		buf.Write(encodeVarint(zigzagEncode(int64(v.Scale))))
+		appendBigInt(buf, v.Unscaled)
+		
+	case *big.Int:
+		if v == nil {
+			return fmt.Errorf("unsupported nil *big.Int")
+		}
+		buf.WriteByte(TypeBigInt)
+		appendBigInt(buf, v)
+		
+	case float64:
+		// Encode float64: [TypeFloat64][8 bytes little-endian IEEE 754]
+		buf.data = AppendFloat64(buf.data, v)
+		
+	case float32:
+		// Encode float32: [TypeFloat32][4 bytes little-endian IEEE 754]
+		buf.data = AppendFloat32(buf.data, v)
+		
+	case *DataInput:
+		// A nil DataInput is a null, as a nil pointer is in Marshal
+		if v == nil {
+			buf.WriteByte(TypeNull)
+			return nil
+		}
+		// Encode DataInput: [TypeDataInput][Count as varint][Elements...]
+		buf.data = AppendListHeader(buf.data, len(v.elements))
+		for _, subElem := range v.elements {
+			if err := encodeElement(buf, subElem); err != nil {
+				return err
+			}
+			if err := buf.flush(streamChunk); err != nil {
+				return err
+			}
+		}
+		
+	case *Map:
+		if v == nil {
+			buf.WriteByte(TypeNull)
+			return nil
+		}
+		// Encode Map: [TypeMap][Count as varint][Key][Value]...
+		buf.WriteByte(TypeMap)
+		buf.Write(encodeVarint(uint64(len(v.entries))))
+		for _, e := range v.entries {
+			if err := encodeElement(buf, e.Key); err != nil {
+				return err
+			}
+			if err := encodeElement(buf, e.Value); err != nil {
+				return err
+			}
+			if err := buf.flush(streamChunk); err != nil {
+				return err
+			}
+		}
+		
+	case *Record:
+		return encodeRecord(buf, v)
+		
+	case Enum:
+		return encodeEnum(buf, v)
+		
+	case netip.Addr:
+		return encodeIPAddr(buf, v)
+		
+	case netip.Prefix:
+		return encodeIPPrefix(buf, v)
+		
+	case RawExtension:
+		// Pass unknown extension elements through unchanged
+		if v.Tag < TypeExtensionMin {
+			return fmt.Errorf("raw extension tag 0x%02x outside reserved range", v.Tag)
+		}
+		writeExtension(buf, v.Tag, v.Payload)
+		
+	case nil:
+		buf.WriteByte(TypeNull)
+		
+	case TypedNull:
+		// Encode typed null: [TypeTypedNull][type tag]
+		if !isValueTag(v.Type) {
+			return fmt.Errorf("typed null of invalid type tag 0x%02x", v.Type)
+		}
+		buf.WriteByte(TypeTypedNull)
+		buf.WriteByte(v.Type)
+		
+	default:
+		// Application-defined types: [Tag][Length as varint][Payload]
+		return encodeExtension(buf, elem)
+	}
+	return nil
+}
+
+// Decode converts a binary message back to its value using
+// DefaultDecoderOptions.
+// A decoded nil always means an encoded TypeNull: corrupt, truncated or
+// over-long input is reported as a *DecodeError.
+// Time Complexity: O(n) where n is the total number of elements
+// Space Complexity: O(m) where m is the total size of decoded data
+func Decode(data []byte) (interface{}, error) {
+	return DecodeWithOptions(data, DefaultDecoderOptions())
+}
+
+// DecodeWithOptions is like Decode but enforces the given limits instead of
+// the defaults.
+func DecodeWithOptions(data []byte, opts DecoderOptions) (interface{}, error) {
+	d := &decoder{data: data, opts: opts}
+	d.enums = append(d.enums, opts.Enums...)
+	if exceeds(uint64(len(data)), opts.MaxMessageBytes) {
+		return nil, d.errorf(0, 0, ErrLimitExceeded,
+			"message size %d exceeds %d bytes", len(data), opts.MaxMessageBytes)
+	}
+	result, offset, err := d.decodeElement(0)
+	if err != nil {
+		return nil, err
+	}
+	if offset != len(data) {
+		return nil, d.errorf(offset, 0, ErrTrailingData, "%d bytes", len(data)-offset)
+	}
+	return result, nil
+}
+
+// decode converts a binary string back to DataInput.
+// It returns nil if the message is invalid; use Decode to tell a decoded
+// null apart from a decoding failure.
+func decode(received string) interface{} {
+	result, err := Decode([]byte(received))
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+// decoder holds the state of a single Decode call
+type decoder struct {
+	data  []byte
+	opts  DecoderOptions
+	path  []int    // index of the current element within each enclosing DataInput
+	total int      // container elements seen so far, for MaxTotalElements
+	names []string    // record name table, see readName
+	enums []*EnumType // enum table, see readEnumType
+}
+
+// errorf builds a DecodeError for the element currently being decoded
+func (d *decoder) errorf(offset int, tag byte, kind error, format string, args ...interface{}) error {
+	return &DecodeError{
+		Offset: offset,
+		Path:   append([]int(nil), d.path...),
+		Tag:    tag,
+		Kind:   kind,
+		Detail: fmt.Sprintf(format, args...),
+	}
+}
+
+// readVarint decodes a varint at offset, returning the value and the offset
+// just past it
+func (d *decoder) readVarint(offset int, tag byte) (uint64, int, error) {
+	n, consumed, err := decodeVarint(d.data[offset:])
+	if err != nil {
+		return 0, 0, d.errorf(offset, tag, err, "reading varint")
+	}
+	return n, offset + consumed, nil
+}
+
+// readLength decodes the varint length prefix at offset and returns the
+// bounds of the payload that follows it
+func (d *decoder) readLength(offset int, tag byte) (int, int, error) {
+	length, offset, err := d.readVarint(offset, tag)
+	if err != nil {
+		return 0, 0, err
+	}
+	if exceeds(length, d.opts.MaxStringBytes) {
+		return 0, 0, d.errorf(offset, tag, ErrLimitExceeded,
+			"length %d exceeds %d bytes", length, d.opts.MaxStringBytes)
+	}
+	// Compare as uint64 so that huge lengths cannot wrap to a negative int
+	if length > uint64(len(d.data)-offset) {
+		return 0, 0, d.errorf(offset, tag, ErrTruncated,
+			"length %d exceeds remaining %d bytes", length, len(d.data)-offset)
+	}
+	return offset, offset + int(length), nil
+}
+
+// readText decodes an untagged [Length as varint][UTF-8 bytes] string, such
+// as a record name or enum symbol, returning it and the offset just past it
+func (d *decoder) readText(offset int, tag byte) (string, int, error) {
+	start, end, err := d.readLength(offset, tag)
+	if err != nil {
+		return "", 0, err
+	}
+	text := string(d.data[start:end])
+	if !utf8.ValidString(text) {
+		return "", 0, d.errorf(start, tag, ErrInvalidUTF8, "%d byte name", end-start)
+	}
+	return text, end, nil
+}
+
+// readString locates the UTF-8 bytes of a TypeString payload, returning
+// their bounds
+func (d *decoder) readString(offset int) (int, int, error) {
+	start, end, err := d.readLength(offset, TypeString)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !utf8.Valid(d.data[start:end]) {
+		return 0, 0, d.errorf(start, TypeString, ErrInvalidUTF8, "%d byte string", end-start)
+	}
+	return start, end, nil
+}
+
+// readInt32 decodes the payload of a TypeInt32 or TypeVarInt32 element
+func (d *decoder) readInt32(offset int, tag byte) (int32, int, error) {
+	if tag == TypeInt32 {
+		// Read 4 bytes for int32
+		if err := d.need(offset, tag, 4); err != nil {
+			return 0, 0, err
+		}
+		return int32(binary.LittleEndian.Uint32(d.data[offset:])), offset + 4, nil
+	}
+	val, next, err := d.readVarint(offset, tag)
+	if err != nil {
+		return 0, 0, err
+	}
+	n := zigzagDecode(val)
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return 0, 0, d.errorf(offset, tag, ErrInvalidValue, "%d overflows int32", n)
+	}
+	return int32(n), next, nil
+}
+
+// need checks that n bytes of fixed-size payload remain at offset
+func (d *decoder) need(offset int, tag byte, n int) error {
+	if n > len(d.data)-offset {
+		return d.errorf(offset, tag, ErrTruncated,
+			"payload needs %d bytes, %d remaining", n, len(d.data)-offset)
+	}
+	return nil
+}
+
+// checkContainer enforces the depth and element count limits before a
+// container with count items of at least minBytes each is allocated
+func (d *decoder) checkContainer(offset int, tag byte, count, minBytes uint64) error {
+	if exceeds(uint64(len(d.path)+1), d.opts.MaxDepth) {
+		return d.errorf(offset, tag, ErrLimitExceeded, "nesting depth exceeds %d", d.opts.MaxDepth)
+	}
+	if err := d.checkCount(offset, tag, count); err != nil {
+		return err
+	}
+	// A count larger than the remaining input can hold is corrupt and must
+	// not size the allocation
+	if count > uint64(len(d.data)-offset)/minBytes {
+		return d.errorf(offset, tag, ErrTruncated,
+			"element count %d exceeds remaining %d bytes", count, len(d.data)-offset)
+	}
+	return nil
+}
+
+// checkCount enforces the per-container and total element count limits and
+// adds count to the total
+func (d *decoder) checkCount(offset int, tag byte, count uint64) error {
+	if exceeds(count, d.opts.MaxElements) {
+		return d.errorf(offset, tag, ErrLimitExceeded,
+			"element count %d exceeds %d", count, d.opts.MaxElements)
+	}
+	if exceeds(uint64(d.total)+count, d.opts.MaxTotalElements) {
+		return d.errorf(offset, tag, ErrLimitExceeded,
+			"total element count exceeds %d", d.opts.MaxTotalElements)
+	}
+	d.total += int(count)
+	return nil
+}
+
+// decodeElement recursively decodes a single element
+// Returns: decoded element, offset just past the element, error
+// Time Complexity: O(1) for primitives, O(k) for strings, O(n) for DataInput
+func (d *decoder) decodeElement(offset int) (interface{}, int, error) {
+	data := d.data
+	if offset >= len(data) {
+		return nil, 0, d.errorf(offset, 0, ErrTruncated, "missing type tag")
+	}
+	
+	typeTag := data[offset]
+	offset++
+	
+	switch typeTag {
+	case TypeString:
+		start, end, err := d.readString(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if d.opts.ZeroCopyStrings {
+			return ZeroCopyString(data[start:end]), end, nil
+		}
+		return string(data[start:end]), end, nil
+		
+	case TypeBytes:
+		// Same layout as a string, without UTF-8 validation
+		start, end, err := d.readLength(offset, typeTag)
+		if err != nil {
+			return nil, 0, err
+		}
+		if d.opts.ZeroCopyBytes {
+			// Cap the capacity so appends by the caller cannot overwrite
+			// the rest of the input
+			return data[start:end:end], end, nil
+		}
+		return append([]byte{}, data[start:end]...), end, nil
+		
+	case TypeInt32, TypeVarInt32:
+		n, next, err := d.readInt32(offset, typeTag)
+		if err != nil {
+			return nil, 0, err
+		}
+		return n, next, nil
+		
+	case TypeInt64:
+		// decodeVarint rejects values that overflow 64 bits
+		val, next, err := d.readVarint(offset, typeTag)
+		if err != nil {
+			return nil, 0, err
+		}
+		return zigzagDecode(val), next, nil
+		
+	case TypeUint64:
+		val, next, err := d.readVarint(offset, typeTag)
+		if err != nil {
+			return nil, 0, err
+		}
+		return val, next, nil
+		
+	case TypeFalse:
+		return false, offset, nil
+		
+	case TypeTrue:
+		return true, offset, nil
+		
+	case TypeTime, TypeTimeTZ:
+		return d.decodeTime(offset, typeTag)
+		
+	case TypeDuration:
+		val, next, err := d.readVarint(offset, typeTag)
+		if err != nil {
+			return nil, 0, err
+		}
+		return time.Duration(zigzagDecode(val)), next, nil
+		
+	case TypeUUID:
+		if err := d.need(offset, typeTag, 16); err != nil {
+			return nil, 0, err
+		}
+		var u UUID
+		copy(u[:], data[offset:offset+16])
+		return u, offset + 16, nil
+		
+	case TypeDecimal:
+		return d.decodeDecimal(offset)
+		
+	case TypeBigInt:
+		n, next, err := d.readBigInt(offset, typeTag)
+		if err != nil {
+			return nil, 0, err
+		}
+		return n, next, nil
+		
+	case TypeFloat64:
+		if err := d.need(offset, typeTag, 8); err != nil {
+			return nil, 0, err
+		}
+		bits := binary.LittleEndian.Uint64(data[offset : offset+8])
+		return math.Float64frombits(bits), offset + 8, nil
+		
+	case TypeFloat32:
+		if err := d.need(offset, typeTag, 4); err != nil {
+			return nil, 0, err
+		}
+		bits := binary.LittleEndian.Uint32(data[offset : offset+4])
+		return math.Float32frombits(bits), offset + 4, nil
+		
+	case TypeDataInput:
+		// Decode element count
+		count, next, err := d.readVarint(offset, typeTag)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset = next
+		if err := d.checkContainer(offset, typeTag, count, 1); err != nil {
+			return nil, 0, err
+		}
+		
+		// Decode each element
+		elements := make([]interface{}, 0, count)
+		d.path = append(d.path, 0)
+		for i := 0; i < int(count); i++ {
+			d.path[len(d.path)-1] = i
+			elem, bytesRead, err := d.decodeElement(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			elements = append(elements, elem)
+			offset = bytesRead
+		}
+		d.path = d.path[:len(d.path)-1]
+		
+		return &DataInput{elements: elements}, offset, nil
+		
+	case TypeMap:
+		return d.decodeMap(offset)
+		
+	case TypeRecord:
+		return d.decodeRecord(offset)
+		
+	case TypeEnum:
+		return d.decodeEnum(offset)
+		
+	case TypeIPAddr:
+		return d.decodeIPAddr(offset)
+		
+	case TypeIPPrefix:
+		return d.decodeIPPrefix(offset)
+		
+	case TypeInt32Array, TypeInt64Array, TypeFloat64Array, TypeBoolArray:
+		return d.decodeArray(offset, typeTag)
+		
+	case TypeNull:
+		return nil, offset, nil
+		
+	case TypeTypedNull:
+		if err := d.need(offset, typeTag, 1); err != nil {
+			return nil, 0, err
+		}
+		if !isValueTag(data[offset]) {
+			return nil, 0, d.errorf(offset, typeTag, ErrInvalidValue,
+				"typed null of invalid type tag 0x%02x", data[offset])
+		}
+		return TypedNull{Type: data[offset]}, offset + 1, nil
+		
+	default:
+		if typeTag >= TypeFixIntMin && typeTag <= TypeFixIntMax {
+			return int32(typeTag-TypeFixIntMin) + fixIntMin, offset, nil
+		}
+		if typeTag >= TypeExtensionMin {
+			return d.decodeExtension(offset, typeTag)
+		}
+		return nil, 0, d.errorf(offset-1, typeTag, ErrUnknownTag, "")
+	}
+}
+
+// maxZoneOffsetMinutes bounds decoded zone offsets to a day either side of UTC
+const maxZoneOffsetMinutes = 24 * 60
+
+// decodeTime decodes the payload of a TypeTime or TypeTimeTZ element
+func (d *decoder) decodeTime(offset int, tag byte) (interface{}, int, error) {
+	secs, offset, err := d.readVarint(offset, tag)
+	if err != nil {
+		return nil, 0, err
+	}
+	nanoOffset := offset
+	nanos, offset, err := d.readVarint(offset, tag)
+	if err != nil {
+		return nil, 0, err
+	}
+	if nanos >= uint64(time.Second) {
+		return nil, 0, d.errorf(nanoOffset, tag, ErrInvalidValue, "nanoseconds %d out of range", nanos)
+	}
+	t := time.Unix(zigzagDecode(secs), int64(nanos))
+	if tag == TypeTime {
+		return t.UTC(), offset, nil
+	}
+
+	zoneOffset := offset
+	zone, offset, err := d.readVarint(offset, tag)
+	if err != nil {
+		return nil, 0, err
+	}
+	minutes := zigzagDecode(zone)
+	if minutes < -maxZoneOffsetMinutes || minutes > maxZoneOffsetMinutes {
+		return nil, 0, d.errorf(zoneOffset, tag, ErrInvalidValue, "zone offset %d minutes out of range", minutes)
+	}
+	return t.In(time.FixedZone("", int(minutes)*60)), offset, nil
+}
+
+// buffer is a simple byte buffer for efficient encoding
+type buffer struct {
+	data  []byte
+	reg   *Registry      // extension codecs; nil means DefaultRegistry
+	names map[string]int // record name table, see writeName
+	enums []*EnumType    // enum table, see writeEnumType
+
+	// An Encoder's buffer streams to w instead of holding the whole message
+	w       io.Writer
+	flushed bool // part of the message has been written to w
+}
+
+func (b *buffer) registry() *Registry {
+	if b.reg == nil {
+		return DefaultRegistry
+	}
+	return b.reg
+}
+
+func (b *buffer) Write(p []byte) {
+	b.data = append(b.data, p...)
+}
+
+func (b *buffer) WriteByte(c byte) error {
+	b.data = append(b.data, c)
+	return nil
+}
+
+// flush writes the buffered data to a streaming buffer's writer once it
+// holds at least min bytes. It is called between elements, so an encoding
+// error in a small message leaves nothing written.
+func (b *buffer) flush(min int) error {
+	if b.w == nil || len(b.data) == 0 || len(b.data) < min {
+		return nil
+	}
+	b.flushed = true
+	_, err := b.w.Write(b.data)
+	b.data = b.data[:0]
+	return err
+}
+
+// direct reports whether a payload of n bytes is large enough to be written
+// straight to a streaming buffer's writer rather than copied into data
+func (b *buffer) direct(n int) bool {
+	return b.w != nil && n >= streamChunk
+}
+
+// flushHeader writes [tag][Length as varint] and everything before it, so
+// that a direct payload can follow
+func (b *buffer) flushHeader(tag byte, n int) error {
+	b.data = append(b.data, tag)
+	b.data = binary.AppendUvarint(b.data, uint64(n))
+	return b.flush(0)
+}
+
+// writeText writes an untagged [Length as varint][UTF-8 bytes] string
+func (b *buffer) writeText(s string) {
+	b.Write(encodeVarint(uint64(len(s))))
+	b.data = append(b.data, s...)
+}
+
+// Equal reports whether two decoded or to-be-encoded values are
+// structurally identical. Extension values are compared by their codec in
+// DefaultRegistry; values of a type registered only in another Registry
+// are never equal, not even to themselves.
+func Equal(a, b interface{}) bool {
+	return compareDataInput(a, b)
+}
+
+// Format returns a human-readable representation of a value, truncating
+// long strings. Extension values are formatted by their codec in
+// DefaultRegistry, and with %v if they have none there.
+func Format(v interface{}) string {
+	return formatDataInput(v)
+}
+
+// isNull reports whether v encodes as a null: nil or a nil container
+func isNull(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case *DataInput:
+		return v == nil
+	case *Map:
+		return v == nil
+	case *Record:
+		return v == nil
+	}
+	return false
+}
+
+// Helper function to compare DataInput structures (for testing)
+func compareDataInput(a, b interface{}) bool {
+	if isNull(a) || isNull(b) {
+		return isNull(a) && isNull(b)
+	}
+	switch va := a.(type) {
+	case string:
+		vb, ok := b.(string)
+		return ok && va == vb
+	case []byte:
+		vb, ok := b.([]byte)
+		return ok && bytes.Equal(va, vb)
+	case []int32, []int64, []float64, []bool:
+		return compareArray(va, b)
+	case int32:
+		vb, ok := b.(int32)
+		return ok && va == vb
+	case int64:
+		switch vb := b.(type) {
+		case int64:
+			return va == vb
+		case int:
+			return va == int64(vb)
+		}
+		return false
+	case int:
+		return compareDataInput(int64(va), b)
+	case uint64:
+		switch vb := b.(type) {
+		case uint64:
+			return va == vb
+		case uint:
+			return va == uint64(vb)
+		}
+		return false
+	case uint:
+		return compareDataInput(uint64(va), b)
+	case bool:
+		vb, ok := b.(bool)
+		return ok && va == vb
+	case time.Time:
+		// Same instant and same zone offset; the *time.Location itself is
+		// not carried on the wire
+		vb, ok := b.(time.Time)
+		if !ok || !va.Equal(vb) {
+			return false
+		}
+		_, oa := va.Zone()
+		_, ob := vb.Zone()
+		return oa/60 == ob/60
+	case time.Duration:
+		vb, ok := b.(time.Duration)
+		return ok && va == vb
+	case UUID:
+		vb, ok := b.(UUID)
+		return ok && va == vb
+	case Decimal:
+		// Scale is significant: 1.0 and 1.00 are different NUMERIC values
+		vb, ok := b.(Decimal)
+		return ok && va.Scale == vb.Scale && compareBigInt(va.Unscaled, vb.Unscaled)
+	case *big.Int:
+		vb, ok := b.(*big.Int)
+		return ok && compareBigInt(va, vb)
+	case float64:
+		// Compare bit patterns so NaN matches NaN and -0 differs from +0
+		vb, ok := b.(float64)
+		return ok && math.Float64bits(va) == math.Float64bits(vb)
+	case float32:
+		vb, ok := b.(float32)
+		return ok && math.Float32bits(va) == math.Float32bits(vb)
+	case *DataInput:
+		vb, ok := b.(*DataInput)
+		if !ok || len(va.elements) != len(vb.elements) {
+			return false
+		}
+		for i := range va.elements {
+			if !compareDataInput(va.elements[i], vb.elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Map:
+		vb, ok := b.(*Map)
+		if !ok || len(va.entries) != len(vb.entries) {
+			return false
+		}
+		for i := range va.entries {
+			if !compareDataInput(va.entries[i].Key, vb.entries[i].Key) ||
+				!compareDataInput(va.entries[i].Value, vb.entries[i].Value) {
+				return false
+			}
+		}
+		return true
+	case *Record:
+		return compareRecord(va, b)
+	case Enum:
+		return compareEnum(va, b)
+	case netip.Addr:
+		vb, ok := b.(netip.Addr)
+		return ok && va == vb
+	case netip.Prefix:
+		vb, ok := b.(netip.Prefix)
+		return ok && va == vb
+	case nil:
+		return b == nil
+	case TypedNull:
+		vb, ok := b.(TypedNull)
+		return ok && va.Type == vb.Type
+	default:
+		return compareExtension(a, b)
+	}
+}
+
+func formatDataInput(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		if len(val) > 50 {
+			return fmt.Sprintf("\"%s...\" (len=%d)", val[:47], len(val))
+		}
+		return fmt.Sprintf("\"%s\"", val)
+	case []byte:
+		if len(val) > 24 {
+			return fmt.Sprintf("0x%x... (len=%d)", val[:23], len(val))
+		}
+		return fmt.Sprintf("0x%x", val)
+	case []int32, []int64, []float64, []bool:
+		return formatArray(val)
+	case int32:
+		return fmt.Sprintf("%d", val)
+	case int64, int, uint64, uint:
+		return fmt.Sprintf("%d", val)
+	case bool:
+		return strconv.FormatBool(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case time.Duration:
+		return val.String()
+	case UUID:
+		return val.String()
+	case Decimal:
+		return val.String()
+	case *big.Int:
+		return val.String()
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32)
+	case *DataInput:
+		if val == nil {
+			return "nil"
+		}
+		result := "DataInput{"
+		for i, elem := range val.elements {
+			if i > 0 {
+				result += ", "
+			}
+			result += formatDataInput(elem)
+		}
+		result += "}"
+		return result
+	case *Map:
+		if val == nil {
+			return "nil"
+		}
+		result := "Map{"
+		for i, e := range val.entries {
+			if i > 0 {
+				result += ", "
+			}
+			result += formatDataInput(e.Key) + ": " + formatDataInput(e.Value)
+		}
+		result += "}"
+		return result
+	case *Record:
+		return formatRecord(val)
+	case Enum:
+		return val.String()
+	case netip.Addr:
+		return val.String()
+	case netip.Prefix:
+		return val.String()
+	case nil:
+		return "nil"
+	case TypedNull:
+		return "NULL(" + typeName(val.Type) + ")"
+	default:
+		if s, ok := formatExtension(val); ok {
+			return s
+		}
+		return fmt.Sprintf("%v", val)
+	}
+}
@@ -0,0 +1,352 @@
+package protocol
+
+import "time"
+
+// Reader decodes a message one element at a time into values whose types
+// the caller already knows, as code generated by cmd/protocol-gen does. It
+// applies DefaultDecoderOptions, and reads a null as the zero value of the
+// requested type.
+//
+// The first error is sticky: every later call returns it, and Done reports
+// it. Errors are *DecodeError values.
+type Reader struct {
+	d      decoder
+	offset int
+	depth  int
+	err    error
+}
+
+// NewReader creates a Reader over an encoded message.
+func NewReader(data []byte) *Reader {
+	r := &Reader{d: decoder{data: data, opts: DefaultDecoderOptions()}}
+	if exceeds(uint64(len(data)), r.d.opts.MaxMessageBytes) {
+		r.err = r.d.errorf(0, 0, ErrLimitExceeded,
+			"message size %d exceeds %d bytes", len(data), r.d.opts.MaxMessageBytes)
+	}
+	return r
+}
+
+// Done returns the first error, or ErrTrailingData if the message goes on
+// past the elements read.
+func (r *Reader) Done() error {
+	if r.err == nil && r.offset != len(r.d.data) {
+		r.err = r.d.errorf(r.offset, 0, ErrTrailingData, "%d bytes", len(r.d.data)-r.offset)
+	}
+	return r.err
+}
+
+// ReadNull consumes the next element and returns true if it is a null,
+// typed or not, and otherwise leaves it to be read.
+func (r *Reader) ReadNull() bool {
+	if r.err != nil || r.offset >= len(r.d.data) {
+		return false
+	}
+	switch r.d.data[r.offset] {
+	case TypeNull:
+		r.offset++
+		return true
+	case TypeTypedNull:
+		if _, err := r.next(); err != nil {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// ReadString reads a string. An Enum reads as its symbol.
+func (r *Reader) ReadString() (string, error) {
+	start := r.offset
+	v, err := r.next()
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case Enum:
+		return s.Symbol(), nil
+	}
+	return "", r.mismatch(start, err, "string")
+}
+
+// ReadBytes reads a byte slice.
+func (r *Reader) ReadBytes() ([]byte, error) {
+	start := r.offset
+	v, err := r.next()
+	if b, ok := v.([]byte); ok {
+		return b, nil
+	}
+	return nil, r.mismatch(start, err, "bytes")
+}
+
+// ReadBool reads a bool.
+func (r *Reader) ReadBool() (bool, error) {
+	start := r.offset
+	v, err := r.next()
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return false, r.mismatch(start, err, "bool")
+}
+
+// ReadInt reads an int32 or int64 that fits in bitSize bits, like
+// strconv.ParseInt.
+func (r *Reader) ReadInt(bitSize int) (int64, error) {
+	start := r.offset
+	v, err := r.next()
+	var n int64
+	switch i := v.(type) {
+	case int32:
+		n = int64(i)
+	case int64:
+		n = i
+	default:
+		return 0, r.mismatch(start, err, "int")
+	}
+	if bitSize < 64 && (n < -1<<(bitSize-1) || n >= 1<<(bitSize-1)) {
+		return 0, r.fail(start, ErrInvalidValue, "%d overflows int%d", n, bitSize)
+	}
+	return n, nil
+}
+
+// ReadUint reads a uint64 that fits in bitSize bits, like
+// strconv.ParseUint.
+func (r *Reader) ReadUint(bitSize int) (uint64, error) {
+	start := r.offset
+	v, err := r.next()
+	n, ok := v.(uint64)
+	if !ok {
+		return 0, r.mismatch(start, err, "uint")
+	}
+	if bitSize < 64 && n >= 1<<bitSize {
+		return 0, r.fail(start, ErrInvalidValue, "%d overflows uint%d", n, bitSize)
+	}
+	return n, nil
+}
+
+// ReadFloat32 reads a float32.
+func (r *Reader) ReadFloat32() (float32, error) {
+	start := r.offset
+	v, err := r.next()
+	if f, ok := v.(float32); ok {
+		return f, nil
+	}
+	return 0, r.mismatch(start, err, "float32")
+}
+
+// ReadFloat64 reads a float64 or float32.
+func (r *Reader) ReadFloat64() (float64, error) {
+	start := r.offset
+	v, err := r.next()
+	switch f := v.(type) {
+	case float64:
+		return f, nil
+	case float32:
+		return float64(f), nil
+	}
+	return 0, r.mismatch(start, err, "float64")
+}
+
+// ReadTime reads a time.Time.
+func (r *Reader) ReadTime() (time.Time, error) {
+	start := r.offset
+	v, err := r.next()
+	if t, ok := v.(time.Time); ok {
+		return t, nil
+	}
+	return time.Time{}, r.mismatch(start, err, "time")
+}
+
+// ReadDuration reads a time.Duration.
+func (r *Reader) ReadDuration() (time.Duration, error) {
+	start := r.offset
+	v, err := r.next()
+	if d, ok := v.(time.Duration); ok {
+		return d, nil
+	}
+	return 0, r.mismatch(start, err, "duration")
+}
+
+// ReadInt32Array reads a packed []int32.
+func (r *Reader) ReadInt32Array() ([]int32, error) {
+	start := r.offset
+	v, err := r.next()
+	if a, ok := v.([]int32); ok {
+		return a, nil
+	}
+	return nil, r.mismatch(start, err, "[]int32")
+}
+
+// ReadInt64Array reads a packed []int64.
+func (r *Reader) ReadInt64Array() ([]int64, error) {
+	start := r.offset
+	v, err := r.next()
+	if a, ok := v.([]int64); ok {
+		return a, nil
+	}
+	return nil, r.mismatch(start, err, "[]int64")
+}
+
+// ReadFloat64Array reads a packed []float64.
+func (r *Reader) ReadFloat64Array() ([]float64, error) {
+	start := r.offset
+	v, err := r.next()
+	if a, ok := v.([]float64); ok {
+		return a, nil
+	}
+	return nil, r.mismatch(start, err, "[]float64")
+}
+
+// ReadBoolArray reads a packed []bool.
+func (r *Reader) ReadBoolArray() ([]bool, error) {
+	start := r.offset
+	v, err := r.next()
+	if a, ok := v.([]bool); ok {
+		return a, nil
+	}
+	return nil, r.mismatch(start, err, "[]bool")
+}
+
+// ReadListHeader reads the header of a DataInput and returns its element
+// count. The elements follow, then a call to EndList.
+func (r *Reader) ReadListHeader() (int, error) {
+	start, err := r.enter(TypeDataInput, "DataInput")
+	if err != nil {
+		return 0, err
+	}
+	count, next, err := r.d.readVarint(start, TypeDataInput)
+	if err != nil {
+		return 0, r.setErr(err)
+	}
+	if err := r.checkCount(next, TypeDataInput, count, 1); err != nil {
+		return 0, err
+	}
+	r.offset = next
+	return int(count), nil
+}
+
+// EndList finishes a DataInput started by ReadListHeader.
+func (r *Reader) EndList() {
+	r.depth--
+}
+
+// ReadRecordHeader reads the header of a Record and returns its field
+// count. Each field follows as ReadFieldName and a value, then a call to
+// EndRecord.
+func (r *Reader) ReadRecordHeader() (int, error) {
+	start, err := r.enter(TypeRecord, "Record")
+	if err != nil {
+		return 0, err
+	}
+	_, next, err := r.d.readName(start)
+	if err != nil {
+		return 0, r.setErr(err)
+	}
+	count, next, err := r.d.readVarint(next, TypeRecord)
+	if err != nil {
+		return 0, r.setErr(err)
+	}
+	// Every field takes at least two bytes, a name reference and a value tag
+	if err := r.checkCount(next, TypeRecord, count, 2); err != nil {
+		return 0, err
+	}
+	r.offset = next
+	return int(count), nil
+}
+
+// ReadFieldName reads the name of the next Record field.
+func (r *Reader) ReadFieldName() (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	name, next, err := r.d.readName(r.offset)
+	if err != nil {
+		return "", r.setErr(err)
+	}
+	r.offset = next
+	return name, nil
+}
+
+// EndRecord finishes a Record started by ReadRecordHeader.
+func (r *Reader) EndRecord() {
+	r.depth--
+}
+
+// next decodes the next element. A null decodes as nil.
+func (r *Reader) next() (interface{}, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	v, next, err := r.d.decodeElement(r.offset)
+	if err != nil {
+		return nil, r.setErr(err)
+	}
+	r.offset = next
+	if _, ok := v.(TypedNull); ok {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// mismatch reports an element at start that is not of the wanted type.
+// Nulls are not mismatches; the caller returns the zero value for them.
+func (r *Reader) mismatch(start int, err error, want string) error {
+	if err != nil {
+		return err
+	}
+	if start < len(r.d.data) {
+		tag := r.d.data[start]
+		if tag == TypeNull || tag == TypeTypedNull {
+			return nil
+		}
+		return r.fail(start, ErrUnexpectedType, "%s where %s was expected", typeName(tag), want)
+	}
+	return r.fail(start, ErrTruncated, "missing type tag")
+}
+
+// enter consumes the tag of a container, checking its type and the depth
+// limit, and returns the offset of its payload
+func (r *Reader) enter(tag byte, want string) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.offset >= len(r.d.data) {
+		return 0, r.fail(r.offset, ErrTruncated, "missing type tag")
+	}
+	if got := r.d.data[r.offset]; got != tag {
+		return 0, r.fail(r.offset, ErrUnexpectedType, "%s where %s was expected", typeName(got), want)
+	}
+	if exceeds(uint64(r.depth+1), r.d.opts.MaxDepth) {
+		return 0, r.fail(r.offset, ErrLimitExceeded, "nesting depth exceeds %d", r.d.opts.MaxDepth)
+	}
+	r.depth++
+	return r.offset + 1, nil
+}
+
+// checkCount applies the element count limits to a container of count
+// items of at least minBytes each
+func (r *Reader) checkCount(offset int, tag byte, count, minBytes uint64) error {
+	if err := r.d.checkCount(offset, tag, count); err != nil {
+		return r.setErr(err)
+	}
+	if count > uint64(len(r.d.data)-offset)/minBytes {
+		return r.setErr(r.d.errorf(offset, tag, ErrTruncated,
+			"element count %d exceeds remaining %d bytes", count, len(r.d.data)-offset))
+	}
+	return nil
+}
+
+// fail records a DecodeError for the element whose tag is at offset
+func (r *Reader) fail(offset int, kind error, format string, args ...interface{}) error {
+	var tag byte
+	if offset < len(r.d.data) {
+		tag = r.d.data[offset]
+	}
+	return r.setErr(r.d.errorf(offset, tag, kind, format, args...))
+}
+
+// setErr records err unless an earlier error is already sticky
+func (r *Reader) setErr(err error) error {
+	if r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
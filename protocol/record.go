@@ -0,0 +1,241 @@
+package protocol
+
+// Records carry a record name and named fields:
+//
+//	[TypeRecord][Name][Field count as varint][Field name][Value]...
+//
+// Every name is a reference into a table built up while a message is
+// written: varint 0 is followed by a literal [Length as varint][UTF-8
+// bytes], which becomes the next table entry, and varint n refers to entry
+// n-1. Repeated records in one message therefore send each name once. The
+// table starts empty for every message.
+
+// Field is a single named value of a Record.
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// Record is a named list of fields, such as a table row or a struct. Field
+// names are unique and fields keep their order on the wire and after
+// decoding.
+type Record struct {
+	name   string
+	fields []Field
+	index  map[string]int // position of each field, once there are more than smallRecord
+}
+
+// smallRecord is the number of fields up to which a linear scan finds a
+// field faster than an index
+const smallRecord = 16
+
+// NewRecord creates a Record holding the given fields. Later fields replace
+// earlier ones with the same name.
+func NewRecord(name string, fields ...Field) *Record {
+	r := &Record{name: name, fields: make([]Field, 0, len(fields))}
+	for _, f := range fields {
+		r.Set(f.Name, f.Value)
+	}
+	return r
+}
+
+// Name returns the record name.
+func (r *Record) Name() string {
+	return r.name
+}
+
+// Len returns the number of fields in the Record.
+func (r *Record) Len() int {
+	return len(r.fields)
+}
+
+// Fields returns the fields of the Record in order.
+func (r *Record) Fields() []Field {
+	return r.fields
+}
+
+// Get returns the value of the named field.
+func (r *Record) Get(name string) (interface{}, bool) {
+	if i := r.find(name); i >= 0 {
+		return r.fields[i].Value, true
+	}
+	return nil, false
+}
+
+// Set stores value in the named field, replacing an existing field in place
+// or appending a new one.
+func (r *Record) Set(name string, value interface{}) {
+	if i := r.find(name); i >= 0 {
+		r.fields[i].Value = value
+		return
+	}
+	r.add(name, value)
+}
+
+// add appends a field without checking for an existing one, indexing the
+// fields once there are too many to scan
+func (r *Record) add(name string, value interface{}) {
+	r.fields = append(r.fields, Field{Name: name, Value: value})
+	switch {
+	case r.index != nil:
+		r.index[name] = len(r.fields) - 1
+	case len(r.fields) > smallRecord:
+		r.index = make(map[string]int, len(r.fields))
+		for i, f := range r.fields {
+			r.index[f.Name] = i
+		}
+	}
+}
+
+// find returns the position of the named field, or -1. Most records are
+// small enough to scan; larger ones, including hostile input, use the
+// index.
+func (r *Record) find(name string) int {
+	if r.index != nil {
+		if i, ok := r.index[name]; ok {
+			return i
+		}
+		return -1
+	}
+	for i, f := range r.fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// encodeRecord writes a Record, interning its names in the buffer's name
+// table. A nil Record is a null.
+func encodeRecord(buf *buffer, r *Record) error {
+	if r == nil {
+		buf.WriteByte(TypeNull)
+		return nil
+	}
+	buf.WriteByte(TypeRecord)
+	buf.writeName(r.name)
+	buf.Write(encodeVarint(uint64(len(r.fields))))
+	for _, f := range r.fields {
+		buf.writeName(f.Name)
+		if err := encodeElement(buf, f.Value); err != nil {
+			return err
+		}
+		if err := buf.flush(streamChunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeName writes a reference to name, adding it to the name table as a
+// literal the first time it is seen
+func (b *buffer) writeName(name string) {
+	if i, ok := b.names[name]; ok {
+		b.Write(encodeVarint(uint64(i) + 1))
+		return
+	}
+	if b.names == nil {
+		b.names = make(map[string]int)
+	}
+	b.names[name] = len(b.names)
+	b.WriteByte(0)
+	b.writeText(name)
+}
+
+// readName decodes a name reference written by writeName
+func (d *decoder) readName(offset int) (string, int, error) {
+	ref, next, err := d.readVarint(offset, TypeRecord)
+	if err != nil {
+		return "", 0, err
+	}
+	if ref > 0 {
+		if ref > uint64(len(d.names)) {
+			return "", 0, d.errorf(offset, TypeRecord, ErrInvalidValue,
+				"name reference %d outside table of %d names", ref, len(d.names))
+		}
+		return d.names[ref-1], next, nil
+	}
+
+	name, end, err := d.readText(next, TypeRecord)
+	if err != nil {
+		return "", 0, err
+	}
+	d.names = append(d.names, name)
+	return name, end, nil
+}
+
+// decodeRecord decodes the payload of a TypeRecord element. A repeated
+// field name is always an error, since Get could not reach the earlier
+// value.
+func (d *decoder) decodeRecord(offset int) (interface{}, int, error) {
+	name, offset, err := d.readName(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, offset, err := d.readVarint(offset, TypeRecord)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Every field takes at least two bytes, a name reference and a value tag
+	if err := d.checkContainer(offset, TypeRecord, count, 2); err != nil {
+		return nil, 0, err
+	}
+
+	r := &Record{name: name, fields: make([]Field, 0, count)}
+	d.path = append(d.path, 0)
+	for i := 0; i < int(count); i++ {
+		d.path[len(d.path)-1] = i
+		fieldOffset := offset
+		fieldName, next, err := d.readName(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if existing := r.find(fieldName); existing >= 0 {
+			return nil, 0, d.errorf(fieldOffset, TypeRecord, ErrDuplicateKey,
+				"field %q repeats field %d", fieldName, existing)
+		}
+		value, next, err := d.decodeElement(next)
+		if err != nil {
+			return nil, 0, err
+		}
+		r.add(fieldName, value)
+		offset = next
+	}
+	d.path = d.path[:len(d.path)-1]
+
+	return r, offset, nil
+}
+
+// compareRecord compares names and fields in order. A nil Record equals
+// only nulls.
+func compareRecord(a *Record, b interface{}) bool {
+	if a == nil {
+		return isNull(b)
+	}
+	rb, ok := b.(*Record)
+	if !ok || rb == nil || a.name != rb.name || len(a.fields) != len(rb.fields) {
+		return false
+	}
+	for i := range a.fields {
+		if a.fields[i].Name != rb.fields[i].Name ||
+			!compareDataInput(a.fields[i].Value, rb.fields[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatRecord formats a Record as `Record(user){id: 1, name: "bob"}`
+func formatRecord(r *Record) string {
+	if r == nil {
+		return "nil"
+	}
+	result := "Record(" + r.name + "){"
+	for i, f := range r.fields {
+		if i > 0 {
+			result += ", "
+		}
+		result += f.Name + ": " + formatDataInput(f.Value)
+	}
+	return result + "}"
+}
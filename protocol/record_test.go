@@ -0,0 +1,168 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestRecordEncoding tests named records and field access
+func TestRecordEncoding(t *testing.T) {
+	user := NewRecord("user",
+		Field{"id", int64(42)},
+		Field{"name", "alice"},
+		Field{"tags", NewDataInput("admin")},
+	)
+	user.Set("active", true)
+	user.Set("name", "bob") // replaces in place
+
+	data := NewDataInput(user, NewRecord("empty"))
+	encoded, err := Encode(data)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !compareDataInput(data, decoded) {
+		t.Fatalf("Encode/Decode mismatch: got %s", formatDataInput(decoded))
+	}
+
+	got := decoded.(*DataInput).elements[0].(*Record)
+	if got.Name() != "user" || got.Len() != 4 {
+		t.Errorf("Name() = %q, Len() = %d; want user, 4", got.Name(), got.Len())
+	}
+	if v, ok := got.Get("name"); !ok || v != "bob" {
+		t.Errorf(`Get("name") = %v, %v; want "bob", true`, v, ok)
+	}
+	if _, ok := got.Get("missing"); ok {
+		t.Error(`Get("missing") found a field`)
+	}
+
+	want := `Record(user){id: 42, name: "bob", tags: DataInput{"admin"}, active: true}`
+	if s := formatDataInput(user); s != want {
+		t.Errorf("formatDataInput = %s, want %s", s, want)
+	}
+	if compareDataInput(NewRecord("a", Field{"x", int32(1)}), NewRecord("b", Field{"x", int32(1)})) {
+		t.Error("records with different names compare equal")
+	}
+	if compareDataInput(NewRecord("a", Field{"x", int32(1)}), NewRecord("a", Field{"y", int32(1)})) {
+		t.Error("records with different field names compare equal")
+	}
+}
+
+// TestRecordNameInterning tests that repeated names are sent once per
+// message
+func TestRecordNameInterning(t *testing.T) {
+	row := func(id int32) *Record {
+		return NewRecord("order", Field{"order_id", id}, Field{"customer", "c1"})
+	}
+	one, err := Encode(NewDataInput(row(1)))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	two, err := Encode(NewDataInput(row(1), row(2)))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// The second record refers to all three names with one byte each: tag,
+	// 3 name references, field count, a fixint and the 4-byte string "c1"
+	if got, want := len(two)-len(one), 1+3+1+1+4; got != want {
+		t.Errorf("second record took %d bytes, want %d", got, want)
+	}
+	if n := bytes.Count(two, []byte("order_id")); n != 1 {
+		t.Errorf("field name written %d times, want 1", n)
+	}
+
+	decoded, err := Decode(two)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !compareDataInput(NewDataInput(row(1), row(2)), decoded) {
+		t.Errorf("Encode/Decode mismatch: got %s", formatDataInput(decoded))
+	}
+
+	// Each message starts with an empty table
+	again, _ := Encode(NewDataInput(row(1)))
+	if !bytes.Equal(one, again) {
+		t.Errorf("name table leaked between messages: %x != %x", again, one)
+	}
+}
+
+// TestRecordErrors tests malformed name references and fields
+func TestRecordErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		kind error
+	}{
+		{"Reference to empty table", []byte{TypeRecord, 0x01, 0x00}, ErrInvalidValue},
+		{"Reference past table", []byte{TypeRecord, 0x00, 0x01, 'r', 0x01, 0x02, TypeNull}, ErrInvalidValue},
+		{"Duplicate field", []byte{TypeRecord, 0x00, 0x01, 'r', 0x02, 0x00, 0x01, 'f', TypeNull, 0x02, TypeNull}, ErrDuplicateKey},
+		{"Invalid UTF-8 name", []byte{TypeRecord, 0x00, 0x02, 0xC3, 0x28, 0x00}, ErrInvalidUTF8},
+		{"Truncated name", []byte{TypeRecord, 0x00, 0x05, 'a'}, ErrTruncated},
+		{"Truncated fields", []byte{TypeRecord, 0x00, 0x01, 'r', 0x03, 0x01, TypeNull}, ErrTruncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); !errors.Is(err, tt.kind) {
+				t.Errorf("Decode error = %v, want %v", err, tt.kind)
+			}
+		})
+	}
+
+	// A record may reuse its own name as a field name
+	self := []byte{TypeRecord, 0x00, 0x01, 'r', 0x01, 0x01, TypeTrue}
+	decoded, err := Decode(self)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if v, ok := decoded.(*Record).Get("r"); !ok || v != true {
+		t.Errorf(`Get("r") = %v, %v; want true, true`, v, ok)
+	}
+}
+
+// TestRecordManyFields tests that a record of many fields decodes in linear
+// time; a linear search for duplicates would take many seconds
+func TestRecordManyFields(t *testing.T) {
+	const count = 40000
+	// encodeFields encodes a record of count distinct fields, all null, and
+	// then a true field reusing the name of each of the extra fields
+	encodeFields := func(extra ...int) []byte {
+		encoded := append([]byte{TypeRecord, 0x00, 0x01, 'r'}, encodeVarint(uint64(count+len(extra)))...)
+		for i := 0; i < count; i++ {
+			name := fmt.Sprintf("f%d", i)
+			encoded = append(append(encoded, 0x00, byte(len(name))), name...)
+			encoded = append(encoded, TypeNull)
+		}
+		for _, i := range extra {
+			// The record name is reference 1, so field i is i+2
+			encoded = append(append(encoded, encodeVarint(uint64(i+2))...), TypeTrue)
+		}
+		return encoded
+	}
+
+	v, err := Decode(encodeFields())
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	r := v.(*Record)
+	if r.Len() != count {
+		t.Errorf("decoded %d fields, want %d", r.Len(), count)
+	}
+	for _, name := range []string{"f0", "f39999"} {
+		if _, ok := r.Get(name); !ok {
+			t.Errorf("Get(%q) found nothing", name)
+		}
+	}
+	r.Set("f20000", true)
+	if v, _ := r.Get("f20000"); v != true || r.Len() != count {
+		t.Errorf("after Set, Get = %v with %d fields", v, r.Len())
+	}
+	if _, err := Decode(encodeFields(7)); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("repeated field: got %v, want ErrDuplicateKey", err)
+	}
+}
@@ -0,0 +1,185 @@
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// The tag range reserved for application-defined types. Extension elements
+// are always written as [Tag][Length as varint][Payload], so a decoder that
+// does not know a tag can still skip it.
+const (
+	TypeExtensionMin byte = 0xF0
+	TypeExtensionMax byte = 0xFF
+)
+
+// TypeCodec encodes and decodes one application-defined Go type under an
+// extension tag.
+type TypeCodec interface {
+	// Type returns the Go type handled by the codec.
+	Type() reflect.Type
+	// Tag returns the extension tag, between TypeExtensionMin and
+	// TypeExtensionMax.
+	Tag() byte
+	// Encode appends the payload of v to dst. The length prefix is written
+	// by the encoder.
+	Encode(dst []byte, v interface{}) ([]byte, error)
+	// Decode converts a payload back to a value. The payload aliases the
+	// decoder's input and must be copied if retained.
+	Decode(payload []byte) (interface{}, error)
+	// Equal reports whether two values of the type are equal. Equal values
+	// must have the same encoding, since Map indexes keys by it.
+	Equal(a, b interface{}) bool
+	// Format returns a human-readable representation of a value.
+	Format(v interface{}) string
+}
+
+// RawExtension is an extension element whose tag has no registered codec.
+// It re-encodes to the same bytes, so unknown types pass through unchanged.
+type RawExtension struct {
+	Tag     byte
+	Payload []byte
+}
+
+// Registry maps extension tags and Go types to their codecs. It is safe for
+// concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	byTag  map[byte]TypeCodec
+	byType map[reflect.Type]TypeCodec
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		byTag:  make(map[byte]TypeCodec),
+		byType: make(map[reflect.Type]TypeCodec),
+	}
+}
+
+// DefaultRegistry is used by Encode and Decode, and when the options leave
+// the registry unset. Equal and Format also consult it.
+var DefaultRegistry = NewRegistry()
+
+// Register adds a codec to DefaultRegistry.
+func Register(c TypeCodec) error {
+	return DefaultRegistry.Register(c)
+}
+
+// Register adds a codec. It fails if the tag is outside the extension range
+// or if the tag or Go type already has a codec. Types the encoder supports
+// natively never reach the registry.
+func (r *Registry) Register(c TypeCodec) error {
+	tag, typ := c.Tag(), c.Type()
+	if tag < TypeExtensionMin {
+		return fmt.Errorf("extension tag 0x%02x outside reserved range 0x%02x-0x%02x",
+			tag, TypeExtensionMin, TypeExtensionMax)
+	}
+	if typ == nil {
+		return fmt.Errorf("extension tag 0x%02x: nil Go type", tag)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.byTag[tag]; ok {
+		return fmt.Errorf("extension tag 0x%02x already registered for %v", tag, existing.Type())
+	}
+	if existing, ok := r.byType[typ]; ok {
+		return fmt.Errorf("type %v already registered with tag 0x%02x", typ, existing.Tag())
+	}
+	r.byTag[tag] = c
+	r.byType[typ] = c
+	return nil
+}
+
+// codecForTag returns the codec for an extension tag, or nil
+func (r *Registry) codecForTag(tag byte) TypeCodec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.byTag[tag]
+}
+
+// codecFor returns the codec for the dynamic type of v, or nil
+func (r *Registry) codecFor(v interface{}) TypeCodec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.byType) == 0 {
+		return nil
+	}
+	return r.byType[reflect.TypeOf(v)]
+}
+
+// encodeExtension writes v with its registered codec, or reports it as
+// unsupported
+func encodeExtension(buf *buffer, v interface{}) error {
+	c := buf.registry().codecFor(v)
+	if c == nil {
+		return fmt.Errorf("unsupported type: %T", v)
+	}
+	payload, err := c.Encode(nil, v)
+	if err != nil {
+		return fmt.Errorf("encoding %T: %w", v, err)
+	}
+	writeExtension(buf, c.Tag(), payload)
+	return nil
+}
+
+// writeExtension writes [Tag][Length as varint][Payload]
+func writeExtension(buf *buffer, tag byte, payload []byte) {
+	buf.WriteByte(tag)
+	buf.Write(encodeVarint(uint64(len(payload))))
+	buf.Write(payload)
+}
+
+// decodeExtension decodes an element in the extension tag range. Tags
+// without a codec are skipped over and returned as RawExtension.
+func (d *decoder) decodeExtension(offset int, tag byte) (interface{}, int, error) {
+	start, end, err := d.readLength(offset, tag)
+	if err != nil {
+		return nil, 0, err
+	}
+	payload := d.data[start:end:end]
+
+	registry := d.opts.Registry
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	c := registry.codecForTag(tag)
+	if c == nil {
+		return RawExtension{Tag: tag, Payload: append([]byte{}, payload...)}, end, nil
+	}
+	v, err := c.Decode(payload)
+	if err != nil {
+		return nil, 0, d.errorf(start, tag, ErrInvalidValue, "%v: %v", c.Type(), err)
+	}
+	return v, end, nil
+}
+
+// compareExtension compares values handled by DefaultRegistry. Equal has no
+// options to name another registry, so a value only a private registry
+// knows compares unequal.
+func compareExtension(a, b interface{}) bool {
+	if ra, ok := a.(RawExtension); ok {
+		rb, ok := b.(RawExtension)
+		return ok && ra.Tag == rb.Tag && bytes.Equal(ra.Payload, rb.Payload)
+	}
+	c := DefaultRegistry.codecFor(a)
+	if c == nil || reflect.TypeOf(b) != c.Type() {
+		return false
+	}
+	return c.Equal(a, b)
+}
+
+// formatExtension formats values handled by DefaultRegistry, reporting
+// false for those it has no codec for
+func formatExtension(v interface{}) (string, bool) {
+	if raw, ok := v.(RawExtension); ok {
+		return fmt.Sprintf("Extension(0x%02x){0x%x}", raw.Tag, raw.Payload), true
+	}
+	if c := DefaultRegistry.codecFor(v); c != nil {
+		return c.Format(v), true
+	}
+	return "", false
+}
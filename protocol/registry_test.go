@@ -0,0 +1,148 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// point is an application-defined type used to exercise the registry
+type point struct {
+	X, Y int32
+}
+
+// pointCodec encodes a point as two little-endian int32s
+type pointCodec struct {
+	tag byte
+}
+
+func (c pointCodec) Type() reflect.Type { return reflect.TypeOf(point{}) }
+func (c pointCodec) Tag() byte          { return c.tag }
+
+func (c pointCodec) Encode(dst []byte, v interface{}) ([]byte, error) {
+	p := v.(point)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(p.X))
+	return binary.LittleEndian.AppendUint32(dst, uint32(p.Y)), nil
+}
+
+func (c pointCodec) Decode(payload []byte) (interface{}, error) {
+	if len(payload) != 8 {
+		return nil, fmt.Errorf("point payload is %d bytes, want 8", len(payload))
+	}
+	return point{
+		X: int32(binary.LittleEndian.Uint32(payload)),
+		Y: int32(binary.LittleEndian.Uint32(payload[4:])),
+	}, nil
+}
+
+func (c pointCodec) Equal(a, b interface{}) bool { return a.(point) == b.(point) }
+func (c pointCodec) Format(v interface{}) string {
+	p := v.(point)
+	return fmt.Sprintf("point(%d, %d)", p.X, p.Y)
+}
+
+// TestRegistryCodec tests encoding and decoding through a registered codec
+func TestRegistryCodec(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.Register(pointCodec{tag: 0xF0}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	data := NewDataInput("origin", point{0, 0}, point{-3, 7})
+	if _, err := Encode(data); err == nil {
+		t.Fatal("Encode with DefaultRegistry accepted an unregistered type")
+	}
+	encoded, err := EncodeWithOptions(data, EncoderOptions{Registry: reg})
+	if err != nil {
+		t.Fatalf("EncodeWithOptions failed: %v", err)
+	}
+
+	opts := DefaultDecoderOptions()
+	opts.Registry = reg
+	decoded, err := DecodeWithOptions(encoded, opts)
+	if err != nil {
+		t.Fatalf("DecodeWithOptions failed: %v", err)
+	}
+	if got := decoded.(*DataInput).elements[2]; got != (point{-3, 7}) {
+		t.Errorf("decoded %v, want point{-3, 7}", got)
+	}
+
+	// Equal and Format only consult DefaultRegistry, so they do not know
+	// the type
+	if Equal(point{1, 2}, point{1, 2}) {
+		t.Error("Equal compared a type registered only in a private registry")
+	}
+	if s := Format(point{1, 2}); s != "{1 2}" {
+		t.Errorf("Format = %q, want the %%v form", s)
+	}
+	if _, ok := NewMap(MapEntry{point{1, 2}, "a"}).Get(point{1, 2}); ok {
+		t.Error("Map found a key registered only in a private registry")
+	}
+
+	// A corrupt payload is reported by the codec
+	bad := []byte{0xF0, 0x01, 0x00}
+	if _, err := DecodeWithOptions(bad, opts); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Decode of bad payload: got %v, want ErrInvalidValue", err)
+	}
+}
+
+// TestUnknownExtension tests that unregistered extension tags are skipped
+// and pass through unchanged
+func TestUnknownExtension(t *testing.T) {
+	encoded := []byte{TypeDataInput, 0x03,
+		0xFA, 0x03, 'a', 'b', 'c',
+		TypeString, 0x02, 'o', 'k',
+		0xFB, 0x00,
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	want := NewDataInput(RawExtension{0xFA, []byte("abc")}, "ok", RawExtension{0xFB, []byte{}})
+	if !compareDataInput(decoded, want) {
+		t.Errorf("Decode = %s, want %s", formatDataInput(decoded), formatDataInput(want))
+	}
+
+	reencoded, err := Encode(decoded)
+	if err != nil || string(reencoded) != string(encoded) {
+		t.Errorf("re-encoded %x, %v; want %x", reencoded, err, encoded)
+	}
+
+	if _, err := Encode(RawExtension{Tag: TypeString}); err == nil {
+		t.Error("Encode accepted a RawExtension with a built-in tag")
+	}
+	if _, err := Decode([]byte{0xFA, 0x05, 'a'}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Decode of truncated extension: got %v, want ErrTruncated", err)
+	}
+}
+
+// TestRegistryRegister tests registration conflicts and the default registry
+func TestRegistryRegister(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.Register(pointCodec{tag: 0xEF}); err == nil {
+		t.Error("Register accepted a tag outside the extension range")
+	}
+	if err := reg.Register(pointCodec{tag: 0xF1}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := reg.Register(pointCodec{tag: 0xF2}); err == nil {
+		t.Error("Register accepted a second codec for the same type")
+	}
+
+	if err := Register(pointCodec{tag: 0xFF}); err != nil {
+		t.Fatalf("Register with DefaultRegistry failed: %v", err)
+	}
+	p := NewDataInput(point{1, 2})
+	decoded := decode(encode(p))
+	if !compareDataInput(p, decoded) {
+		t.Errorf("Encode/Decode mismatch: got %s", formatDataInput(decoded))
+	}
+	if got := formatDataInput(decoded); got != "DataInput{point(1, 2)}" {
+		t.Errorf("formatDataInput = %s", got)
+	}
+	if compareDataInput(point{1, 2}, point{2, 1}) {
+		t.Error("compareDataInput ignores the codec's Equal")
+	}
+}
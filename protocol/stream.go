@@ -0,0 +1,450 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// streamChunk is the size at which an Encoder writes out what it has
+// encoded so far, and the least a Decoder asks its reader for
+const streamChunk = 32 << 10
+
+// maxEmptyReads bounds the reads returning neither data nor an error that a
+// Decoder tolerates, like bufio.Reader
+const maxEmptyReads = 100
+
+// Encoder writes a sequence of messages to an io.Writer. A message is
+// written out in pieces of about 32 KiB as it is encoded, and large strings
+// and byte slices go straight from the value to the writer, so a large
+// message is never held in memory whole.
+type Encoder struct {
+	buf  buffer
+	opts EncoderOptions
+	err  error // sticky error that ends the stream
+}
+
+// NewEncoder creates an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return NewEncoderWithOptions(w, EncoderOptions{})
+}
+
+// NewEncoderWithOptions is like NewEncoder but uses the given options.
+func NewEncoderWithOptions(w io.Writer, opts EncoderOptions) *Encoder {
+	return &Encoder{
+		buf:  buffer{data: make([]byte, 0, 1024), reg: opts.Registry, w: w},
+		opts: opts,
+	}
+}
+
+// Encode writes v as the next message, in the format of Encode. Record
+// names and enum tables are interned per message.
+//
+// If v cannot be encoded nothing is written, unless the message had already
+// grown past the first piece. The stream is then left incomplete and, as
+// after an error from the writer, every later call returns the error.
+func (e *Encoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	b := &e.buf
+	b.data = b.data[:0]
+	b.enums = append(b.enums[:0], e.opts.Enums...)
+	clear(b.names)
+	b.flushed = false
+
+	err := encodeElement(b, v)
+	if err == nil {
+		err = b.flush(0)
+	}
+	if err != nil && b.flushed {
+		e.err = err
+	}
+	return err
+}
+
+// Decoder reads a sequence of messages from an io.Reader. It only reads
+// while the input buffered so far ends inside a message, so it never waits
+// for input beyond the message being decoded, and any it has read past it
+// is available from Buffered.
+type Decoder struct {
+	r    io.Reader
+	opts DecoderOptions
+	buf  []byte  // input read but not yet decoded
+	scan scanner // progress through the message at the start of buf
+	rerr error   // read error to report once buf is used up
+	err  error   // sticky error that ends the stream
+}
+
+// NewDecoder creates a Decoder reading from r with DefaultDecoderOptions.
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(r, DefaultDecoderOptions())
+}
+
+// NewDecoderWithOptions is like NewDecoder but enforces the given limits
+// instead of the defaults. MaxMessageBytes also bounds how much input is
+// buffered for a single message.
+func NewDecoderWithOptions(r io.Reader, opts DecoderOptions) *Decoder {
+	dec := &Decoder{r: r, opts: opts}
+	dec.scan.reset()
+	return dec
+}
+
+// Decode reads the next message and returns its value, like Decode. It
+// returns io.EOF once the input ends between messages; input that ends
+// inside a message is an ErrTruncated DecodeError.
+//
+// A complete message that fails to decode is skipped and its error
+// returned, so the next call continues with the following message. Read
+// errors, and corruption that hides where a message ends, are returned by
+// every later call.
+func (dec *Decoder) Decode() (interface{}, error) {
+	if dec.err != nil {
+		return nil, dec.err
+	}
+	n, err := dec.next()
+	if err != nil {
+		dec.err = err
+		return nil, err
+	}
+	msg := dec.buf[:n:n]
+	dec.buf = dec.buf[n:]
+	dec.scan.reset()
+	return DecodeWithOptions(msg, dec.opts)
+}
+
+// Buffered returns the input that has been read but not yet decoded.
+func (dec *Decoder) Buffered() io.Reader {
+	return bytes.NewReader(dec.buf)
+}
+
+// next reads until buf holds a whole message and returns its length
+func (dec *Decoder) next() (int, error) {
+	for {
+		d := decoder{data: dec.buf, opts: dec.opts}
+		n, err := dec.scan.scan(&d)
+		if err == nil {
+			return n, nil
+		}
+		if !errors.Is(err, ErrTruncated) {
+			return 0, err
+		}
+		if exceeds(uint64(len(dec.buf)), dec.opts.MaxMessageBytes) {
+			return 0, d.errorf(0, 0, ErrLimitExceeded,
+				"message size exceeds %d bytes", dec.opts.MaxMessageBytes)
+		}
+		if rerr := dec.fill(); rerr != nil {
+			if rerr != io.EOF {
+				return 0, rerr
+			}
+			if len(dec.buf) == 0 {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+	}
+}
+
+// fill reads more input onto the end of buf. Bytes already in buf are never
+// overwritten, since with DecoderOptions.ZeroCopyBytes or ZeroCopyStrings
+// earlier messages may still refer to them.
+func (dec *Decoder) fill() error {
+	if dec.rerr != nil {
+		return dec.rerr
+	}
+	if cap(dec.buf)-len(dec.buf) < streamChunk/8 {
+		grown := make([]byte, len(dec.buf), 2*len(dec.buf)+streamChunk)
+		copy(grown, dec.buf)
+		dec.buf = grown
+	}
+	for i := 0; i < maxEmptyReads; i++ {
+		n, err := dec.r.Read(dec.buf[len(dec.buf):cap(dec.buf)])
+		dec.buf = dec.buf[:len(dec.buf)+n]
+		if n > 0 {
+			// Keep the error for when this data is used up
+			dec.rerr = err
+			return nil
+		}
+		if err != nil {
+			dec.rerr = err
+			return err
+		}
+	}
+	return io.ErrNoProgress
+}
+
+// scanItem is the kind of item a scanFrame counts
+type scanItem uint8
+
+const (
+	scanElement scanItem = iota // a tagged element
+	scanField                   // a record field name reference and value
+	scanText                    // an untagged enum symbol
+	scanVarint                  // an untagged varint, the enum index
+)
+
+// scanFrame is a run of items still to be scanned inside depth containers
+type scanFrame struct {
+	item  scanItem
+	count uint64
+	depth int
+}
+
+// scanner finds where a message ends without decoding it, so a Decoder
+// knows how much input to read. It keeps its place between calls: an item
+// cut off by the end of the input is scanned again once more has been read,
+// so a message arriving in small pieces is still scanned in linear time.
+type scanner struct {
+	offset int         // end of the items scanned so far
+	stack  []scanFrame // items still to be scanned, innermost last
+	names  bool        // resolve record names into d.names; needs the whole message
+}
+
+// reset starts the scan of a new message
+func (s *scanner) reset() {
+	s.offset = 0
+	s.stack = append(s.stack[:0], scanFrame{item: scanElement, count: 1})
+}
+
+// scan continues through d.data and returns the length of the message once
+// it is complete. An ErrTruncated error means more input is needed.
+func (s *scanner) scan(d *decoder) (int, error) {
+	for len(s.stack) > 0 {
+		top := len(s.stack) - 1
+		f := s.stack[top]
+		if f.count == 0 {
+			s.stack = s.stack[:top]
+			continue
+		}
+		next, err := s.item(d, f)
+		if err != nil {
+			return 0, err
+		}
+		s.stack[top].count--
+		s.offset = next
+	}
+	return s.offset, nil
+}
+
+// item scans the next item of f and returns the offset just past it. Frames
+// for the items it contains are only pushed once all of it is present.
+func (s *scanner) item(d *decoder, f scanFrame) (int, error) {
+	switch f.item {
+	case scanField:
+		next, err := s.name(d, s.offset)
+		if err != nil {
+			return 0, err
+		}
+		s.push(scanElement, 1, f.depth)
+		return next, nil
+	case scanText:
+		_, end, err := d.readLength(s.offset, TypeEnum)
+		return end, err
+	case scanVarint:
+		return skipVarints(d, s.offset, TypeEnum, 1)
+	}
+	return s.element(d, s.offset, f.depth)
+}
+
+// element skips the element at offset, checking only what its size and
+// nesting depend on; everything else is left to decodeElement
+func (s *scanner) element(d *decoder, offset, depth int) (int, error) {
+	if offset >= len(d.data) {
+		return 0, d.errorf(offset, 0, ErrTruncated, "missing type tag")
+	}
+	tag := d.data[offset]
+	offset++
+
+	switch tag {
+	case TypeNull, TypeFalse, TypeTrue:
+		return offset, nil
+
+	case TypeString, TypeBytes:
+		_, end, err := d.readLength(offset, tag)
+		return end, err
+
+	case TypeInt32, TypeFloat32:
+		return offset + 4, d.need(offset, tag, 4)
+
+	case TypeFloat64:
+		return offset + 8, d.need(offset, tag, 8)
+
+	case TypeUUID:
+		return offset + 16, d.need(offset, tag, 16)
+
+	case TypeTypedNull:
+		return offset + 1, d.need(offset, tag, 1)
+
+	case TypeVarInt32, TypeInt64, TypeUint64, TypeDuration:
+		return skipVarints(d, offset, tag, 1)
+
+	case TypeTime:
+		return skipVarints(d, offset, tag, 2)
+
+	case TypeTimeTZ:
+		return skipVarints(d, offset, tag, 3)
+
+	case TypeDecimal:
+		next, err := skipVarints(d, offset, tag, 1)
+		if err != nil {
+			return 0, err
+		}
+		return skipBigInt(d, next, tag)
+
+	case TypeBigInt:
+		return skipBigInt(d, offset, tag)
+
+	case TypeIPAddr:
+		_, next, err := d.readAddr(offset, tag)
+		return next, err
+
+	case TypeIPPrefix:
+		_, next, err := d.readAddr(offset, tag)
+		if err != nil {
+			return 0, err
+		}
+		return next + 1, d.need(next, tag, 1)
+
+	case TypeInt32Array, TypeInt64Array, TypeFloat64Array, TypeBoolArray:
+		bits := 64
+		switch tag {
+		case TypeInt32Array:
+			bits = 32
+		case TypeBoolArray:
+			bits = 1
+		}
+		count, next, err := d.readArrayHeader(offset, tag, uint64(bits))
+		if err != nil {
+			return 0, err
+		}
+		return next + (count*bits+7)/8, nil
+
+	case TypeDataInput, TypeMap:
+		count, next, err := d.readVarint(offset, tag)
+		if err != nil {
+			return 0, err
+		}
+		if err := checkScanContainer(d, next, tag, count, depth); err != nil {
+			return 0, err
+		}
+		s.push(scanElement, count, depth+1)
+		if tag == TypeMap {
+			// Keys and values are both elements, so a map of count entries
+			// is as long as 2*count elements
+			s.push(scanElement, count, depth+1)
+		}
+		return next, nil
+
+	case TypeRecord:
+		next, err := s.name(d, offset)
+		if err != nil {
+			return 0, err
+		}
+		count, next, err := d.readVarint(next, tag)
+		if err != nil {
+			return 0, err
+		}
+		if err := checkScanContainer(d, next, tag, count, depth); err != nil {
+			return 0, err
+		}
+		s.push(scanField, count, depth+1)
+		return next, nil
+
+	case TypeEnum:
+		ref, next, err := d.readVarint(offset, tag)
+		if err != nil {
+			return 0, err
+		}
+		if ref > 0 {
+			return skipVarints(d, next, tag, 1)
+		}
+		_, next, err = d.readLength(next, tag)
+		if err != nil {
+			return 0, err
+		}
+		count, next, err := d.readVarint(next, tag)
+		if err != nil {
+			return 0, err
+		}
+		if err := d.checkCount(next, tag, count); err != nil {
+			return 0, err
+		}
+		// The symbols come first, then the index
+		s.push(scanVarint, 1, depth)
+		s.push(scanText, count, depth)
+		return next, nil
+
+	default:
+		if tag >= TypeFixIntMin && tag <= TypeFixIntMax {
+			return offset, nil
+		}
+		if tag >= TypeExtensionMin {
+			_, end, err := d.readLength(offset, tag)
+			return end, err
+		}
+		return 0, d.errorf(offset-1, tag, ErrUnknownTag, "")
+	}
+}
+
+// push adds a frame of count items
+func (s *scanner) push(item scanItem, count uint64, depth int) {
+	s.stack = append(s.stack, scanFrame{item: item, count: count, depth: depth})
+}
+
+// name skips the record name reference at offset, or resolves it if
+// s.names is set
+func (s *scanner) name(d *decoder, offset int) (int, error) {
+	if s.names {
+		_, next, err := d.readName(offset)
+		return next, err
+	}
+	return skipName(d, offset)
+}
+
+// checkScanContainer applies the depth and element count limits to a
+// container of count items nested inside depth others
+func checkScanContainer(d *decoder, offset int, tag byte, count uint64, depth int) error {
+	if exceeds(uint64(depth+1), d.opts.MaxDepth) {
+		return d.errorf(offset, tag, ErrLimitExceeded, "nesting depth exceeds %d", d.opts.MaxDepth)
+	}
+	return d.checkCount(offset, tag, count)
+}
+
+// skipVarints skips n varints at offset
+func skipVarints(d *decoder, offset int, tag byte, n int) (int, error) {
+	for i := 0; i < n; i++ {
+		var err error
+		if _, offset, err = d.readVarint(offset, tag); err != nil {
+			return 0, err
+		}
+	}
+	return offset, nil
+}
+
+// skipBigInt skips an integer written by appendBigInt
+func skipBigInt(d *decoder, offset int, tag byte) (int, error) {
+	header, offset, err := d.readVarint(offset, tag)
+	if err != nil {
+		return 0, err
+	}
+	length := header >> 1
+	if exceeds(length, d.opts.MaxStringBytes) {
+		return 0, d.errorf(offset, tag, ErrLimitExceeded,
+			"magnitude length %d exceeds %d bytes", length, d.opts.MaxStringBytes)
+	}
+	if length > uint64(len(d.data)-offset) {
+		return 0, d.errorf(offset, tag, ErrTruncated,
+			"magnitude length %d exceeds remaining %d bytes", length, len(d.data)-offset)
+	}
+	return offset + int(length), nil
+}
+
+// skipName skips a name reference written by writeName
+func skipName(d *decoder, offset int) (int, error) {
+	ref, next, err := d.readVarint(offset, TypeRecord)
+	if err != nil || ref > 0 {
+		return next, err
+	}
+	_, end, err := d.readLength(next, TypeRecord)
+	return end, err
+}
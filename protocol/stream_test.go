@@ -0,0 +1,251 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// writeRecorder records the size of every Write
+type writeRecorder struct {
+	buf   bytes.Buffer
+	sizes []int
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.sizes = append(w.sizes, len(p))
+	return w.buf.Write(p)
+}
+
+// failingWriter fails every Write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+// TestStreamRoundTrip tests a sequence of messages through an Encoder and
+// a Decoder reading one byte at a time
+func TestStreamRoundTrip(t *testing.T) {
+	rows := NewDataInput()
+	for i := 0; i < 20000; i++ {
+		rows.Append(NewRecord("row", Field{"id", int32(i)}, Field{"name", "user"}))
+	}
+	messages := []interface{}{
+		NewDataInput("foo", int32(42)),
+		nil,
+		strings.Repeat("x", 100<<10),
+		rows,
+		NewMap(MapEntry{"k", []byte("v")}),
+		NewRecord("row", Field{"id", int32(-1)}),
+	}
+	for _, seed := range fuzzSeeds() {
+		v, err := Decode(seed)
+		if err != nil {
+			t.Fatalf("Decode(seed) failed: %v", err)
+		}
+		messages = append(messages, v)
+	}
+
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	for _, m := range messages {
+		if err := enc.Encode(m); err != nil {
+			t.Fatalf("Encode(%.40s) failed: %v", formatDataInput(m), err)
+		}
+	}
+
+	for _, r := range []struct {
+		name   string
+		reader io.Reader
+	}{
+		{"Whole", bytes.NewReader(w.Bytes())},
+		{"OneByte", iotest.OneByteReader(bytes.NewReader(w.Bytes()))},
+		{"DataErr", iotest.DataErrReader(bytes.NewReader(w.Bytes()))},
+	} {
+		t.Run(r.name, func(t *testing.T) {
+			dec := NewDecoder(r.reader)
+			for i, want := range messages {
+				got, err := dec.Decode()
+				if err != nil {
+					t.Fatalf("Decode of message %d failed: %v", i, err)
+				}
+				if !compareDataInput(want, got) {
+					t.Fatalf("message %d = %.80s, want %.80s", i, formatDataInput(got), formatDataInput(want))
+				}
+			}
+			if _, err := dec.Decode(); err != io.EOF {
+				t.Errorf("Decode at end = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+// TestEncoderStreams tests that large messages are written in pieces
+func TestEncoderStreams(t *testing.T) {
+	rows := NewDataInput()
+	for i := 0; i < 50000; i++ {
+		rows.Append(NewDataInput(int32(i), "name"))
+	}
+	big := strings.Repeat("x", 100<<10)
+	msg := NewDataInput(rows, big)
+
+	var w writeRecorder
+	if err := NewEncoder(&w).Encode(msg); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	want, _ := Encode(msg)
+	if !bytes.Equal(w.buf.Bytes(), want) {
+		t.Fatal("streamed message differs from Encode")
+	}
+	if len(w.sizes) < 5 {
+		t.Errorf("%d byte message written in %d pieces", w.buf.Len(), len(w.sizes))
+	}
+	for _, n := range w.sizes {
+		if n > 2*streamChunk && n != len(big) {
+			t.Errorf("wrote a %d byte piece", n)
+		}
+	}
+	if w.sizes[len(w.sizes)-1] != len(big) {
+		t.Errorf("large string written in a %d byte piece, want it passed through", w.sizes[len(w.sizes)-1])
+	}
+}
+
+// TestEncoderErrors tests which errors end the stream
+func TestEncoderErrors(t *testing.T) {
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	if err := enc.Encode(NewDataInput("a", struct{}{})); err == nil {
+		t.Fatal("Encode of unsupported value succeeded")
+	}
+	if w.Len() != 0 {
+		t.Errorf("failed message wrote %d bytes", w.Len())
+	}
+	if err := enc.Encode("b"); err != nil {
+		t.Fatalf("Encode after a small failed message: %v", err)
+	}
+	if v, err := NewDecoder(&w).Decode(); err != nil || v != "b" {
+		t.Errorf("Decode = %v, %v, want b", v, err)
+	}
+
+	// Once part of a message is out, the stream cannot be repaired
+	enc = NewEncoder(&w)
+	rows := NewDataInput()
+	for i := 0; i < 10000; i++ {
+		rows.Append("row")
+	}
+	rows.Append(struct{}{})
+	err := enc.Encode(rows)
+	if err == nil {
+		t.Fatal("Encode of unsupported value succeeded")
+	}
+	if again := enc.Encode("c"); again != err {
+		t.Errorf("Encode after a partly written message = %v, want %v", again, err)
+	}
+
+	enc = NewEncoder(failingWriter{})
+	if err := enc.Encode("a"); err == nil || enc.Encode("b") != err {
+		t.Errorf("write error = %v, want it returned by every call", err)
+	}
+}
+
+// TestDecoderErrors tests truncated, invalid and oversized input
+func TestDecoderErrors(t *testing.T) {
+	good, _ := Encode(NewDataInput("ok"))
+	invalid := []byte{TypeString, 2, 0xC3, 0x28}
+
+	// A complete message that fails to decode does not end the stream
+	input := append(append(append([]byte{}, good...), invalid...), good...)
+	dec := NewDecoder(bytes.NewReader(input))
+	if _, err := dec.Decode(); err != nil {
+		t.Fatalf("first Decode failed: %v", err)
+	}
+	if _, err := dec.Decode(); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Decode of invalid message = %v, want %v", err, ErrInvalidUTF8)
+	}
+	if v, err := dec.Decode(); err != nil || !compareDataInput(v, NewDataInput("ok")) {
+		t.Errorf("Decode after invalid message = %v, %v", v, err)
+	}
+
+	// Input ending inside a message is truncated, and stays an error
+	dec = NewDecoder(iotest.OneByteReader(bytes.NewReader(good[:len(good)-1])))
+	_, err := dec.Decode()
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Kind != ErrTruncated {
+		t.Fatalf("Decode of truncated message = %v, want %v", err, ErrTruncated)
+	}
+	if _, again := dec.Decode(); again != err {
+		t.Errorf("Decode after truncation = %v, want %v", again, err)
+	}
+
+	// An unknown tag hides where the message ends
+	dec = NewDecoder(bytes.NewReader(append([]byte{TypeDataInput, 2, 0x40}, good...)))
+	if _, err := dec.Decode(); !errors.Is(err, ErrUnknownTag) {
+		t.Errorf("Decode = %v, want %v", err, ErrUnknownTag)
+	}
+
+	// Oversized messages are rejected without reading all of them
+	opts := DefaultDecoderOptions()
+	opts.MaxMessageBytes = 1 << 10
+	huge, _ := Encode(strings.Repeat("x", 1<<20))
+	r := bytes.NewReader(huge)
+	dec = NewDecoderWithOptions(r, opts)
+	if _, err := dec.Decode(); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Decode of oversized message = %v, want %v", err, ErrLimitExceeded)
+	}
+	if r.Len() == 0 {
+		t.Error("oversized message was read in full")
+	}
+
+	readErr := errors.New("connection reset")
+	dec = NewDecoder(io.MultiReader(bytes.NewReader(good), iotest.ErrReader(readErr)))
+	if _, err := dec.Decode(); err != nil {
+		t.Errorf("Decode before read error = %v", err)
+	}
+	if _, err := dec.Decode(); err != readErr {
+		t.Errorf("Decode = %v, want %v", err, readErr)
+	}
+}
+
+// TestDecoderBuffered tests that a Decoder does not wait for input past the
+// message it decodes
+func TestDecoderBuffered(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		NewEncoder(pw).Encode("request")
+	}()
+	dec := NewDecoder(pr)
+	if v, err := dec.Decode(); err != nil || v != "request" {
+		t.Fatalf("Decode = %v, %v", v, err)
+	}
+	pw.Close()
+
+	input, _ := Encode(int32(1))
+	dec = NewDecoder(bytes.NewReader(append(input, "rest"...)))
+	if _, err := dec.Decode(); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if rest, _ := io.ReadAll(dec.Buffered()); string(rest) != "rest" {
+		t.Errorf("Buffered = %q, want rest", rest)
+	}
+}
+
+// BenchmarkDecoderOneByte measures scanning a large message that arrives in
+// small reads
+func BenchmarkDecoderOneByte(b *testing.B) {
+	rows := NewDataInput()
+	for i := 0; i < 10000; i++ {
+		rows.Append(NewDataInput(int32(i), "name"))
+	}
+	data, _ := Encode(rows)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		dec := NewDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+		if _, err := dec.Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
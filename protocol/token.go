@@ -0,0 +1,442 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TokenKind identifies the kind of a Token.
+type TokenKind uint8
+
+const (
+	// TokenNull is a null; Value holds the TypedNull of a typed one.
+	TokenNull TokenKind = iota + 1
+	// TokenString is a string; Raw holds its UTF-8 bytes.
+	TokenString
+	// TokenBytes is a byte slice; Raw holds it.
+	TokenBytes
+	// TokenInt32 is an int32 in any of its forms; Int holds it.
+	TokenInt32
+	// TokenValue is any other scalar; Value holds it as Decode returns it.
+	TokenValue
+	// TokenBeginList starts a DataInput of Count elements.
+	TokenBeginList
+	// TokenEndList ends a DataInput.
+	TokenEndList
+	// TokenBeginMap starts a Map of Count entries, each a key then a value.
+	TokenBeginMap
+	// TokenEndMap ends a Map.
+	TokenEndMap
+	// TokenBeginRecord starts a Record called Name with Count fields, each
+	// a TokenField then a value.
+	TokenBeginRecord
+	// TokenField is the name of the next Record field, held in Name.
+	TokenField
+	// TokenEndRecord ends a Record.
+	TokenEndRecord
+)
+
+var tokenKindNames = [...]string{
+	TokenNull:        "Null",
+	TokenString:      "String",
+	TokenBytes:       "Bytes",
+	TokenInt32:       "Int32",
+	TokenValue:       "Value",
+	TokenBeginList:   "BeginList",
+	TokenEndList:     "EndList",
+	TokenBeginMap:    "BeginMap",
+	TokenEndMap:      "EndMap",
+	TokenBeginRecord: "BeginRecord",
+	TokenField:       "Field",
+	TokenEndRecord:   "EndRecord",
+}
+
+func (k TokenKind) String() string {
+	if int(k) < len(tokenKindNames) && tokenKindNames[k] != "" {
+		return tokenKindNames[k]
+	}
+	return fmt.Sprintf("TokenKind(%d)", uint8(k))
+}
+
+// Token is one step through an encoded message: a scalar value, the start
+// or end of a container, or a record field name.
+type Token struct {
+	Kind   TokenKind
+	Offset int         // offset in the message of the token; End tokens are past the container
+	Tag    byte        // type tag of a value or container start, otherwise 0
+	Count  int         // BeginList: elements; BeginMap: entries; BeginRecord: fields
+	Name   string      // BeginRecord: the record name; Field: the field name
+	Raw    []byte      // String, Bytes: the payload
+	Int    int64       // Int32: the value
+	Value  interface{} // Value: the value; Null: nil or a TypedNull
+}
+
+// tokenFrame tracks an open container while reading or writing tokens
+type tokenFrame struct {
+	end   TokenKind // token that closes the container
+	count int       // items in the container; a map entry is two
+	next  int       // items read or written so far
+	named bool      // the current record field's name has been seen
+}
+
+// Tokenizer reads an encoded message one token at a time, without building
+// the tree that Decode returns. Strings and byte slices are not copied:
+// Token.Raw refers to the input, which must not be modified while tokens are
+// in use.
+//
+// It applies the DecoderOptions limits like Decode and reports the same
+// DecodeErrors, except that repeated record fields and map keys are not
+// checked. The first error is sticky.
+type Tokenizer struct {
+	d       decoder
+	offset  int
+	stack   []tokenFrame
+	started bool
+	err     error
+}
+
+// NewTokenizer creates a Tokenizer over an encoded message with
+// DefaultDecoderOptions.
+func NewTokenizer(data []byte) *Tokenizer {
+	return NewTokenizerWithOptions(data, DefaultDecoderOptions())
+}
+
+// NewTokenizerWithOptions is like NewTokenizer but enforces the given
+// limits instead of the defaults.
+func NewTokenizerWithOptions(data []byte, opts DecoderOptions) *Tokenizer {
+	t := &Tokenizer{d: decoder{data: data, opts: opts}}
+	t.d.enums = append(t.d.enums, opts.Enums...)
+	if exceeds(uint64(len(data)), opts.MaxMessageBytes) {
+		t.err = t.d.errorf(0, 0, ErrLimitExceeded,
+			"message size %d exceeds %d bytes", len(data), opts.MaxMessageBytes)
+	}
+	return t
+}
+
+// Next returns the next token, or io.EOF after the last one.
+func (t *Tokenizer) Next() (Token, error) {
+	if t.err != nil {
+		return Token{}, t.err
+	}
+	tok, err := t.next()
+	if err != nil {
+		t.err = err
+	}
+	return tok, err
+}
+
+// Depth returns the number of containers that have been started and not
+// yet ended.
+func (t *Tokenizer) Depth() int {
+	return len(t.stack)
+}
+
+// Skip reads past the next value, all of it if it is a container, and
+// returns its first token. A Field token is skipped along with its value.
+func (t *Tokenizer) Skip() (Token, error) {
+	depth := len(t.stack)
+	first, err := t.Next()
+	if err != nil {
+		return Token{}, err
+	}
+	if first.Kind == TokenField {
+		if _, err := t.Skip(); err != nil {
+			return Token{}, err
+		}
+		return first, nil
+	}
+	for len(t.stack) > depth {
+		if _, err := t.Next(); err != nil {
+			return Token{}, err
+		}
+	}
+	return first, nil
+}
+
+// next reads the token at t.offset
+func (t *Tokenizer) next() (Token, error) {
+	d := &t.d
+	if len(t.stack) == 0 {
+		if !t.started {
+			t.started = true
+			return t.element()
+		}
+		if t.offset != len(d.data) {
+			return Token{}, d.errorf(t.offset, 0, ErrTrailingData, "%d bytes", len(d.data)-t.offset)
+		}
+		return Token{}, io.EOF
+	}
+
+	top := &t.stack[len(t.stack)-1]
+	if top.next == top.count {
+		end := top.end
+		t.stack = t.stack[:len(t.stack)-1]
+		d.path = d.path[:len(d.path)-1]
+		return Token{Kind: end, Offset: t.offset}, nil
+	}
+	if top.end == TokenEndRecord && !top.named {
+		d.path[len(d.path)-1] = top.next
+		name, next, err := d.readName(t.offset)
+		if err != nil {
+			return Token{}, err
+		}
+		top.named = true
+		tok := Token{Kind: TokenField, Offset: t.offset, Name: name}
+		t.offset = next
+		return tok, nil
+	}
+	index := top.next
+	if top.end == TokenEndMap {
+		index /= 2
+	}
+	d.path[len(d.path)-1] = index
+	top.next++
+	top.named = false
+	return t.element()
+}
+
+// element reads the value or container start at t.offset
+func (t *Tokenizer) element() (Token, error) {
+	d := &t.d
+	offset := t.offset
+	if offset >= len(d.data) {
+		return Token{}, d.errorf(offset, 0, ErrTruncated, "missing type tag")
+	}
+	tag := d.data[offset]
+	tok := Token{Offset: offset, Tag: tag}
+	offset++
+
+	switch {
+	case tag == TypeNull:
+		tok.Kind = TokenNull
+		t.offset = offset
+
+	case tag == TypeString:
+		start, end, err := d.readString(offset)
+		if err != nil {
+			return Token{}, err
+		}
+		tok.Kind, tok.Raw = TokenString, d.data[start:end:end]
+		t.offset = end
+
+	case tag == TypeBytes:
+		start, end, err := d.readLength(offset, tag)
+		if err != nil {
+			return Token{}, err
+		}
+		tok.Kind, tok.Raw = TokenBytes, d.data[start:end:end]
+		t.offset = end
+
+	case tag == TypeInt32 || tag == TypeVarInt32:
+		n, next, err := d.readInt32(offset, tag)
+		if err != nil {
+			return Token{}, err
+		}
+		tok.Kind, tok.Int = TokenInt32, int64(n)
+		t.offset = next
+
+	case tag >= TypeFixIntMin && tag <= TypeFixIntMax:
+		tok.Kind, tok.Int = TokenInt32, int64(tag-TypeFixIntMin)+fixIntMin
+		t.offset = offset
+
+	case tag == TypeDataInput || tag == TypeMap:
+		count, next, err := d.readVarint(offset, tag)
+		if err != nil {
+			return Token{}, err
+		}
+		tok.Kind, tok.Count = TokenBeginList, int(count)
+		minBytes, end, items := uint64(1), TokenEndList, int(count)
+		if tag == TypeMap {
+			tok.Kind = TokenBeginMap
+			minBytes, end, items = 2, TokenEndMap, 2*int(count)
+		}
+		if err := d.checkContainer(next, tag, count, minBytes); err != nil {
+			return Token{}, err
+		}
+		t.push(end, items)
+		t.offset = next
+
+	case tag == TypeRecord:
+		name, next, err := d.readName(offset)
+		if err != nil {
+			return Token{}, err
+		}
+		count, next, err := d.readVarint(next, tag)
+		if err != nil {
+			return Token{}, err
+		}
+		// Every field takes at least two bytes, a name reference and a
+		// value tag
+		if err := d.checkContainer(next, tag, count, 2); err != nil {
+			return Token{}, err
+		}
+		tok.Kind, tok.Name, tok.Count = TokenBeginRecord, name, int(count)
+		t.push(TokenEndRecord, int(count))
+		t.offset = next
+
+	default:
+		v, next, err := d.decodeElement(tok.Offset)
+		if err != nil {
+			return Token{}, err
+		}
+		tok.Kind, tok.Value = TokenValue, v
+		if tag == TypeTypedNull {
+			tok.Kind = TokenNull
+		}
+		t.offset = next
+	}
+	return tok, nil
+}
+
+// push opens a container of count items
+func (t *Tokenizer) push(end TokenKind, count int) {
+	t.stack = append(t.stack, tokenFrame{end: end, count: count})
+	t.d.path = append(t.d.path, 0)
+}
+
+// TokenWriter writes a sequence of messages token by token to an
+// io.Writer, in the format of Encode. Containers are written with the
+// counts their Begin tokens give, so exactly that many values must follow.
+// Like an Encoder, it writes large messages out in pieces and interns
+// record names and enum tables per message.
+//
+// Tokens read by a Tokenizer can be written back unchanged. The first error
+// is sticky.
+type TokenWriter struct {
+	buf   buffer
+	opts  EncoderOptions
+	stack []tokenFrame
+	err   error
+}
+
+// NewTokenWriter creates a TokenWriter writing to w.
+func NewTokenWriter(w io.Writer) *TokenWriter {
+	return NewTokenWriterWithOptions(w, EncoderOptions{})
+}
+
+// NewTokenWriterWithOptions is like NewTokenWriter but uses the given
+// options.
+func NewTokenWriterWithOptions(w io.Writer, opts EncoderOptions) *TokenWriter {
+	return &TokenWriter{
+		buf:  buffer{data: make([]byte, 0, 1024), reg: opts.Registry, w: w},
+		opts: opts,
+	}
+}
+
+// WriteToken writes the next token. Offset and Tag are ignored. A message
+// is written out in full once its last token has been written.
+func (w *TokenWriter) WriteToken(tok Token) error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.writeToken(tok); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Depth returns the number of containers that have been started and not
+// yet ended.
+func (w *TokenWriter) Depth() int {
+	return len(w.stack)
+}
+
+// writeToken writes a token, checking that it fits the open containers
+func (w *TokenWriter) writeToken(tok Token) error {
+	b := &w.buf
+	if len(w.stack) == 0 {
+		// Start a new message
+		b.data = b.data[:0]
+		b.enums = append(b.enums[:0], w.opts.Enums...)
+		clear(b.names)
+	} else {
+		top := &w.stack[len(w.stack)-1]
+		switch {
+		case top.next == top.count:
+			if tok.Kind != top.end {
+				return fmt.Errorf("unexpected %s token, want %s", tok.Kind, top.end)
+			}
+			w.stack = w.stack[:len(w.stack)-1]
+			return w.endValue()
+		case top.end == TokenEndRecord && !top.named:
+			if tok.Kind != TokenField {
+				return fmt.Errorf("unexpected %s token, want Field", tok.Kind)
+			}
+			b.writeName(tok.Name)
+			top.named = true
+			return nil
+		}
+		top.next++
+		top.named = false
+	}
+
+	switch tok.Kind {
+	case TokenNull:
+		if tok.Value != nil {
+			if _, ok := tok.Value.(TypedNull); !ok {
+				return fmt.Errorf("null token holding %T", tok.Value)
+			}
+		}
+		if err := encodeElement(b, tok.Value); err != nil {
+			return err
+		}
+
+	case TokenString, TokenBytes:
+		tag := TypeString
+		if tok.Kind == TokenBytes {
+			tag = TypeBytes
+		}
+		b.data = append(b.data, tag)
+		b.data = binary.AppendUvarint(b.data, uint64(len(tok.Raw)))
+		b.data = append(b.data, tok.Raw...)
+
+	case TokenInt32:
+		if tok.Int != int64(int32(tok.Int)) {
+			return fmt.Errorf("int32 token holding %d", tok.Int)
+		}
+		b.data = AppendInt32(b.data, int32(tok.Int))
+
+	case TokenValue:
+		if err := encodeElement(b, tok.Value); err != nil {
+			return err
+		}
+
+	case TokenBeginList, TokenBeginMap, TokenBeginRecord:
+		if tok.Count < 0 {
+			return fmt.Errorf("%s token with negative count %d", tok.Kind, tok.Count)
+		}
+		items := tok.Count
+		switch tok.Kind {
+		case TokenBeginList:
+			b.data = AppendListHeader(b.data, tok.Count)
+			w.stack = append(w.stack, tokenFrame{end: TokenEndList, count: items})
+		case TokenBeginMap:
+			b.WriteByte(TypeMap)
+			b.Write(encodeVarint(uint64(tok.Count)))
+			w.stack = append(w.stack, tokenFrame{end: TokenEndMap, count: 2 * items})
+		default:
+			b.WriteByte(TypeRecord)
+			b.writeName(tok.Name)
+			b.Write(encodeVarint(uint64(tok.Count)))
+			w.stack = append(w.stack, tokenFrame{end: TokenEndRecord, count: items})
+		}
+		return b.flush(streamChunk)
+
+	default:
+		if len(w.stack) == 0 {
+			return fmt.Errorf("unexpected %s token outside a container", tok.Kind)
+		}
+		return fmt.Errorf("unexpected %s token, want a value", tok.Kind)
+	}
+	return w.endValue()
+}
+
+// endValue writes out the message if the value just written completed it
+func (w *TokenWriter) endValue() error {
+	if len(w.stack) == 0 {
+		return w.buf.flush(0)
+	}
+	return w.buf.flush(streamChunk)
+}
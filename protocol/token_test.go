@@ -0,0 +1,263 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tokenize reads every token of a message
+func tokenize(t testing.TB, data []byte) []Token {
+	t.Helper()
+	tz := NewTokenizer(data)
+	var tokens []Token
+	for {
+		tok, err := tz.Next()
+		if err == io.EOF {
+			return tokens
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// TestTokenizer tests the tokens and offsets of a small message
+func TestTokenizer(t *testing.T) {
+	data, err := Encode(NewDataInput("foo", int32(42), nil, NewDataInput(),
+		NewRecord("r", Field{"id", int32(-300)}), NewMap(MapEntry{[]byte{1}, TypedNull{TypeInt64}})))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	want := []Token{
+		{Kind: TokenBeginList, Offset: 0, Tag: TypeDataInput, Count: 6},
+		{Kind: TokenString, Offset: 2, Tag: TypeString, Raw: []byte("foo")},
+		{Kind: TokenInt32, Offset: 7, Tag: TypeFixIntMin + 58, Int: 42},
+		{Kind: TokenNull, Offset: 8, Tag: TypeNull},
+		{Kind: TokenBeginList, Offset: 9, Tag: TypeDataInput},
+		{Kind: TokenEndList, Offset: 11},
+		{Kind: TokenBeginRecord, Offset: 11, Tag: TypeRecord, Name: "r", Count: 1},
+		{Kind: TokenField, Offset: 16, Name: "id"},
+		{Kind: TokenInt32, Offset: 20, Tag: TypeVarInt32, Int: -300},
+		{Kind: TokenEndRecord, Offset: 23},
+		{Kind: TokenBeginMap, Offset: 23, Tag: TypeMap, Count: 1},
+		{Kind: TokenBytes, Offset: 25, Tag: TypeBytes, Raw: []byte{1}},
+		{Kind: TokenNull, Offset: 28, Tag: TypeTypedNull, Value: TypedNull{TypeInt64}},
+		{Kind: TokenEndMap, Offset: 30},
+		{Kind: TokenEndList, Offset: 30},
+	}
+	got := tokenize(t, data)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens = %+v\nwant %+v", got, want)
+	}
+	if len(data) != 30 {
+		t.Errorf("message is %d bytes, want 30", len(data))
+	}
+}
+
+// TestTokenRoundTrip tests that tokens written back reproduce the message
+func TestTokenRoundTrip(t *testing.T) {
+	for _, seed := range fuzzSeeds() {
+		var w bytes.Buffer
+		tw := NewTokenWriter(&w)
+		for _, tok := range tokenize(t, seed) {
+			if err := tw.WriteToken(tok); err != nil {
+				t.Fatalf("WriteToken(%v) failed: %v", tok.Kind, err)
+			}
+		}
+		if tw.Depth() != 0 || !bytes.Equal(w.Bytes(), seed) {
+			t.Errorf("tokens of %x written back as %x", seed, w.Bytes())
+		}
+	}
+
+	// Several messages in a row each get their own name table
+	var w bytes.Buffer
+	tw := NewTokenWriter(&w)
+	for i := 0; i < 2; i++ {
+		for _, tok := range []Token{
+			{Kind: TokenBeginRecord, Name: "row", Count: 1},
+			{Kind: TokenField, Name: "id"},
+			{Kind: TokenValue, Value: int64(i)},
+			{Kind: TokenEndRecord},
+		} {
+			if err := tw.WriteToken(tok); err != nil {
+				t.Fatalf("WriteToken failed: %v", err)
+			}
+		}
+	}
+	dec := NewDecoder(&w)
+	for i := 0; i < 2; i++ {
+		v, err := dec.Decode()
+		if want := NewRecord("row", Field{"id", int64(i)}); err != nil || !compareDataInput(v, want) {
+			t.Errorf("message %d = %s, %v, want %s", i, formatDataInput(v), err, formatDataInput(want))
+		}
+	}
+}
+
+// TestTokenizerErrors tests that tokenizing reports the errors of Decode
+func TestTokenizerErrors(t *testing.T) {
+	nested := NewDataInput(int32(1))
+	for i := 0; i < 70; i++ {
+		nested = NewDataInput(nested)
+	}
+	deep, _ := Encode(nested)
+	valid, _ := Encode(NewDataInput("abc", NewRecord("r", Field{"a", int32(1)})))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Truncated", valid[:len(valid)-1]},
+		{"Trailing data", append(append([]byte{}, valid...), 0)},
+		{"Invalid UTF-8", []byte{TypeDataInput, 1, TypeString, 2, 0xC3, 0x28}},
+		{"Unknown tag", []byte{TypeDataInput, 2, TypeNull, 0x40}},
+		{"Name reference", []byte{TypeRecord, 1, 0}},
+		{"Too deep", deep},
+		{"Overflow", []byte{TypeVarInt32, 0xFF, 0xFF, 0xFF, 0xFF, 0x1F}},
+		{"Empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, want := Decode(tt.data)
+			if want == nil {
+				t.Fatal("Decode succeeded")
+			}
+			tz := NewTokenizer(tt.data)
+			var err error
+			for err == nil {
+				_, err = tz.Next()
+			}
+			if !reflect.DeepEqual(err, want) {
+				t.Errorf("Next error = %v, want %v", err, want)
+			}
+			if _, again := tz.Next(); again != err {
+				t.Errorf("Next after error = %v, want %v", again, err)
+			}
+		})
+	}
+}
+
+// TestTokenizerSkip tests finding a field without reading the others
+func TestTokenizerSkip(t *testing.T) {
+	row := NewRecord("row",
+		Field{"tags", NewDataInput("a", NewMap(MapEntry{"k", NewDataInput()}))},
+		Field{"name", "alice"},
+		Field{"id", int32(7)},
+	)
+	data, _ := Encode(NewDataInput(row, "after"))
+	tz := NewTokenizer(data)
+	tz.Next() // BeginList
+	tz.Next() // BeginRecord
+	var names []string
+	for {
+		tok, err := tz.Skip()
+		if err != nil {
+			t.Fatalf("Skip failed: %v", err)
+		}
+		if tok.Kind == TokenEndRecord {
+			break
+		}
+		if tok.Kind != TokenField {
+			t.Fatalf("Skip returned %v, want Field", tok.Kind)
+		}
+		names = append(names, tok.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"tags", "name", "id"}) || tz.Depth() != 1 {
+		t.Errorf("skipped fields %q to depth %d", names, tz.Depth())
+	}
+	if tok, err := tz.Next(); err != nil || string(tok.Raw) != "after" {
+		t.Errorf("Next = %+v, %v, want after", tok, err)
+	}
+}
+
+// TestTokenWriterErrors tests token sequences that do not form a message
+func TestTokenWriterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []Token
+		want   string
+	}{
+		{"Short list", []Token{{Kind: TokenBeginList, Count: 2}, {Kind: TokenNull}, {Kind: TokenEndList}}, "unexpected EndList token, want a value"},
+		{"Long list", []Token{{Kind: TokenBeginList, Count: 0}, {Kind: TokenNull}}, "unexpected Null token, want EndList"},
+		{"Missing field", []Token{{Kind: TokenBeginRecord, Name: "r", Count: 1}, {Kind: TokenNull}}, "unexpected Null token, want Field"},
+		{"Field in list", []Token{{Kind: TokenBeginList, Count: 1}, {Kind: TokenField, Name: "x"}}, "unexpected Field token, want a value"},
+		{"End outside", []Token{{Kind: TokenEndMap}}, "outside a container"},
+		{"Int32 range", []Token{{Kind: TokenInt32, Int: 1 << 40}}, "int32 token holding 1099511627776"},
+		{"Null value", []Token{{Kind: TokenNull, Value: "x"}}, "null token holding string"},
+		{"Negative count", []Token{{Kind: TokenBeginMap, Count: -1}}, "negative count"},
+		{"Unsupported", []Token{{Kind: TokenValue, Value: struct{}{}}}, "unsupported type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w bytes.Buffer
+			tw := NewTokenWriter(&w)
+			var err error
+			for _, tok := range tt.tokens {
+				if err = tw.WriteToken(tok); err != nil {
+					break
+				}
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("WriteToken error = %v, want %q", err, tt.want)
+			}
+			if tw.WriteToken(Token{Kind: TokenNull}) != err {
+				t.Error("error is not sticky")
+			}
+			if w.Len() != 0 {
+				t.Errorf("incomplete message wrote %d bytes", w.Len())
+			}
+		})
+	}
+}
+
+// BenchmarkTokenizerScan measures finding one value in a large message
+func BenchmarkTokenizerScan(b *testing.B) {
+	data := benchmarkRows(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tz := NewTokenizer(data)
+		var found bool
+		for !found {
+			tok, err := tz.Next()
+			if err != nil {
+				b.Fatal(err)
+			}
+			found = tok.Kind == TokenString && string(tok.Raw) == "needle"
+		}
+	}
+}
+
+// BenchmarkDecodeScan measures the same search through the decoded tree
+func BenchmarkDecodeScan(b *testing.B) {
+	data := benchmarkRows(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v, err := Decode(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		rows := v.(*DataInput).Elements()
+		if last := rows[len(rows)-1].(*DataInput).Elements(); last[1] != "needle" {
+			b.Fatal("needle not found")
+		}
+	}
+}
+
+// benchmarkRows returns 10000 rows with "needle" in the last one
+func benchmarkRows(b *testing.B) []byte {
+	rows := NewDataInput()
+	for i := 0; i < 10000; i++ {
+		rows.Append(NewDataInput(int32(i), "haystack", 3.5))
+	}
+	rows.Append(NewDataInput(int32(-1), "needle", 0.0))
+	data, err := Encode(rows)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
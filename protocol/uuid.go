@@ -0,0 +1,44 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// UUID is a 128-bit universally unique identifier, encoded as its 16 raw
+// bytes.
+type UUID [16]byte
+
+// ParseUUID parses the canonical text form
+// "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx", in upper or lower case.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	var src [32]byte
+	copy(src[0:8], s[0:8])
+	copy(src[8:12], s[9:13])
+	copy(src[12:16], s[14:18])
+	copy(src[16:20], s[19:23])
+	copy(src[20:32], s[24:36])
+	if _, err := hex.Decode(u[:], src[:]); err != nil {
+		return UUID{}, fmt.Errorf("invalid UUID %q: %v", s, err)
+	}
+	return u, nil
+}
+
+// String returns the canonical lower-case text form of the UUID.
+func (u UUID) String() string {
+	var dst [36]byte
+	hex.Encode(dst[0:8], u[0:4])
+	dst[8] = '-'
+	hex.Encode(dst[9:13], u[4:6])
+	dst[13] = '-'
+	hex.Encode(dst[14:18], u[6:8])
+	dst[18] = '-'
+	hex.Encode(dst[19:23], u[8:10])
+	dst[23] = '-'
+	hex.Encode(dst[24:36], u[10:16])
+	return string(dst[:])
+}
@@ -0,0 +1,55 @@
+package protocol
+
+import "testing"
+
+// TestUUIDEncoding tests UUID text parsing and 17 byte encoding
+func TestUUIDEncoding(t *testing.T) {
+	const text = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	u, err := ParseUUID(text)
+	if err != nil {
+		t.Fatalf("ParseUUID failed: %v", err)
+	}
+	if u.String() != text {
+		t.Errorf("String() = %s, want %s", u.String(), text)
+	}
+	upper, err := ParseUUID("6BA7B810-9DAD-11D1-80B4-00C04FD430C8")
+	if err != nil || upper != u {
+		t.Errorf("ParseUUID(upper case) = %v, %v; want %v", upper, err, u)
+	}
+
+	encoded, err := Encode(u)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if len(encoded) != 17 {
+		t.Errorf("encoded size = %d, want 17", len(encoded))
+	}
+	decoded, err := Decode(encoded)
+	if err != nil || decoded != u {
+		t.Errorf("Decode = %v, %v; want %v", decoded, err, u)
+	}
+
+	data := NewDataInput(u, UUID{}, text)
+	if decoded := decode(encode(data)); !compareDataInput(data, decoded) {
+		t.Errorf("Encode/Decode mismatch: got %s", formatDataInput(decoded))
+	}
+	if compareDataInput(u, text) {
+		t.Error("compareDataInput treats a UUID and its text as equal")
+	}
+
+	for _, bad := range []string{
+		"",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c",
+		"6ba7b8109dad11d180b400c04fd430c8",
+		"6ba7b810-9dad-11d1-80b4_00c04fd430c8",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430cg",
+	} {
+		if _, err := ParseUUID(bad); err == nil {
+			t.Errorf("ParseUUID(%q) succeeded, want error", bad)
+		}
+	}
+
+	if _, err := Decode(encoded[:10]); err == nil {
+		t.Error("Decode of truncated UUID succeeded")
+	}
+}
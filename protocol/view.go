@@ -0,0 +1,335 @@
+package protocol
+
+import "fmt"
+
+// View reads values out of an encoded message where they lie, without
+// decoding the rest of it. Index finds an element by skipping the ones
+// before it and remembers where each of them starts, so reading a few
+// values of a large message costs a scan past the others instead of a
+// decoded copy of all of them.
+//
+// A View checks only what it reads: the values it skips are checked for
+// their size and nesting but not their content, and input past the end of
+// the top-level value is ignored. The DecoderOptions limits apply as in
+// Decode, except MaxTotalElements, which bounds what Decode allocates.
+// Errors are *DecodeError values without a Path. A View that could not be
+// reached reports why from Err and from every method that returns an error.
+//
+// Views of the same message share their index, so they must not be used
+// from several goroutines at once. The input must not be modified while
+// they are in use.
+type View struct {
+	m      *viewMessage
+	offset int   // offset of the type tag
+	depth  int   // containers around the value
+	tag    byte  // type tag
+	count  int   // DataInput elements, Map entries or Record fields
+	body   int   // offset of the first item of a container
+	err    error // why the value could not be reached
+}
+
+// viewMessage is the state shared by the Views of one message
+type viewMessage struct {
+	d        decoder
+	scan     scanner
+	items    map[int][]int // offsets of the items of each container found so far, by tag offset
+	names    []string      // record name table of the whole message, see name
+	namesErr error
+	loaded   bool
+}
+
+// NewView returns a View of the top-level value of an encoded message with
+// DefaultDecoderOptions.
+func NewView(data []byte) View {
+	return NewViewWithOptions(data, DefaultDecoderOptions())
+}
+
+// NewViewWithOptions is like NewView but enforces the given limits instead
+// of the defaults.
+func NewViewWithOptions(data []byte, opts DecoderOptions) View {
+	opts.MaxTotalElements = 0
+	m := &viewMessage{d: decoder{data: data, opts: opts}}
+	if exceeds(uint64(len(data)), opts.MaxMessageBytes) {
+		return View{m: m, err: m.d.errorf(0, 0, ErrLimitExceeded,
+			"message size %d exceeds %d bytes", len(data), opts.MaxMessageBytes)}
+	}
+	return m.view(0, 0)
+}
+
+// Err returns the error that kept the value from being reached, if any.
+func (v View) Err() error {
+	return v.err
+}
+
+// Type returns the type tag of the value, reporting every int32 encoding
+// as TypeInt32. It returns TypeNull if Err is not nil.
+func (v View) Type() byte {
+	switch {
+	case v.err != nil:
+		return TypeNull
+	case v.tag == TypeVarInt32 || v.tag >= TypeFixIntMin && v.tag <= TypeFixIntMax:
+		return TypeInt32
+	}
+	return v.tag
+}
+
+// Len returns the number of elements of a DataInput, entries of a Map or
+// fields of a Record, and 0 for any other value.
+func (v View) Len() int {
+	return v.count
+}
+
+// Index returns element i of a DataInput, the value of entry i of a Map or
+// the value of field i of a Record.
+func (v View) Index(i int) View {
+	if err := v.at(i, 0); err != nil {
+		return View{m: v.m, err: err}
+	}
+	var offset int
+	var err error
+	switch v.tag {
+	case TypeMap:
+		offset, err = v.m.item(v, 2*i+1)
+	case TypeRecord:
+		if offset, err = v.m.item(v, i); err == nil {
+			offset, err = skipName(&v.m.d, offset)
+		}
+	default:
+		offset, err = v.m.item(v, i)
+	}
+	if err != nil {
+		return View{m: v.m, err: err}
+	}
+	return v.m.view(offset, v.depth+1)
+}
+
+// Key returns the key of entry i of a Map.
+func (v View) Key(i int) View {
+	if err := v.at(i, TypeMap); err != nil {
+		return View{m: v.m, err: err}
+	}
+	offset, err := v.m.item(v, 2*i)
+	if err != nil {
+		return View{m: v.m, err: err}
+	}
+	return v.m.view(offset, v.depth+1)
+}
+
+// Name returns the name of a Record. Names are interned, so the first call
+// to Name or Field scans the whole message for them.
+func (v View) Name() (string, error) {
+	if err := v.want(TypeRecord); err != nil {
+		return "", err
+	}
+	return v.m.name(v.offset + 1)
+}
+
+// Field returns the name of field i of a Record.
+func (v View) Field(i int) (string, error) {
+	if err := v.at(i, TypeRecord); err != nil {
+		return "", err
+	}
+	offset, err := v.m.item(v, i)
+	if err != nil {
+		return "", err
+	}
+	return v.m.name(offset)
+}
+
+// String returns the value of a string, or "" for a null. With
+// DecoderOptions.ZeroCopyStrings it shares the memory of the input.
+func (v View) String() (string, error) {
+	if v.err != nil {
+		return "", v.err
+	}
+	if v.tag != TypeString {
+		return "", v.other("string")
+	}
+	d := &v.m.d
+	start, end, err := d.readString(v.offset + 1)
+	if err != nil {
+		return "", err
+	}
+	if d.opts.ZeroCopyStrings {
+		return ZeroCopyString(d.data[start:end]), nil
+	}
+	return string(d.data[start:end]), nil
+}
+
+// Int32 returns the value of an int32 in any of its encodings, or 0 for a
+// null.
+func (v View) Int32() (int32, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	switch {
+	case v.tag == TypeInt32 || v.tag == TypeVarInt32:
+		n, _, err := v.m.d.readInt32(v.offset+1, v.tag)
+		return n, err
+	case v.tag >= TypeFixIntMin && v.tag <= TypeFixIntMax:
+		return int32(v.tag-TypeFixIntMin) + fixIntMin, nil
+	}
+	return 0, v.other("int32")
+}
+
+// want checks that the value is a container, of type tag unless tag is 0
+func (v View) want(tag byte) error {
+	if v.err != nil {
+		return v.err
+	}
+	want, ok := typeName(tag), v.tag == tag
+	if tag == 0 {
+		want, ok = "a container", v.tag == TypeDataInput || v.tag == TypeMap || v.tag == TypeRecord
+	}
+	if !ok {
+		return v.m.d.errorf(v.offset, v.tag, ErrUnexpectedType,
+			"%s where %s was expected", typeName(v.Type()), want)
+	}
+	return nil
+}
+
+// at checks that the value is a container as for want, with an entry i
+func (v View) at(i int, tag byte) error {
+	if err := v.want(tag); err != nil {
+		return err
+	}
+	if i < 0 || i >= v.count {
+		return fmt.Errorf("index %d out of range for %s of length %d", i, typeName(v.tag), v.count)
+	}
+	return nil
+}
+
+// other reports a value that is not of the wanted type. Nulls are not
+// mismatches; they read as the zero value.
+func (v View) other(want string) error {
+	switch v.tag {
+	case TypeNull:
+		return nil
+	case TypeTypedNull:
+		_, _, err := v.m.d.decodeElement(v.offset)
+		return err
+	}
+	return v.m.d.errorf(v.offset, v.tag, ErrUnexpectedType,
+		"%s where %s was expected", typeName(v.Type()), want)
+}
+
+// view reads the type tag at offset, and the header if it starts a
+// container
+func (m *viewMessage) view(offset, depth int) View {
+	v := View{m: m, offset: offset, depth: depth}
+	d := &m.d
+	if offset >= len(d.data) {
+		v.err = d.errorf(offset, 0, ErrTruncated, "missing type tag")
+		return v
+	}
+	v.tag = d.data[offset]
+	next, minBytes := offset+1, uint64(1)
+	switch v.tag {
+	case TypeDataInput:
+	case TypeMap:
+		minBytes = 2
+	case TypeRecord:
+		// Every field takes at least two bytes, a name reference and a
+		// value tag
+		var err error
+		if next, err = skipName(d, next); err != nil {
+			v.err = err
+			return v
+		}
+		minBytes = 2
+	case TypeNull, TypeTypedNull:
+		return v
+	default:
+		if !isValueTag(v.tag) && (v.tag < TypeFixIntMin || v.tag > TypeFixIntMax) {
+			v.err = d.errorf(offset, v.tag, ErrUnknownTag, "")
+		}
+		return v
+	}
+
+	count, next, err := d.readVarint(next, v.tag)
+	if err == nil {
+		err = checkScanContainer(d, next, v.tag, count, depth)
+	}
+	if err == nil && count > uint64(len(d.data)-next)/minBytes {
+		err = d.errorf(next, v.tag, ErrTruncated,
+			"element count %d exceeds remaining %d bytes", count, len(d.data)-next)
+	}
+	if err != nil {
+		v.err = err
+		return v
+	}
+	v.count, v.body = int(count), next
+	return v
+}
+
+// item returns the offset of item i of the container v, skipping the items
+// before it that have not been found yet. A Map has a key and a value item
+// for each entry, and a Record a name and value item for each field.
+func (m *viewMessage) item(v View, i int) (int, error) {
+	if m.items == nil {
+		m.items = make(map[int][]int)
+	}
+	items := m.items[v.offset]
+	if items == nil {
+		items = []int{v.body}
+	}
+	var err error
+	for len(items) <= i && err == nil {
+		var next int
+		if next, err = m.skip(items[len(items)-1], v.tag == TypeRecord, v.depth+1); err == nil {
+			items = append(items, next)
+		}
+	}
+	m.items[v.offset] = items
+	if err != nil {
+		return 0, err
+	}
+	return items[i], nil
+}
+
+// skip returns the offset just past the element, or the record field if
+// field is set, at offset inside depth containers
+func (m *viewMessage) skip(offset int, field bool, depth int) (int, error) {
+	s := &m.scan
+	s.stack = s.stack[:0]
+	if field {
+		s.offset = offset
+		s.push(scanField, 1, depth)
+		return s.scan(&m.d)
+	}
+	// A scalar is skipped without the scan loop; a container leaves frames
+	// for its items
+	next, err := s.element(&m.d, offset, depth)
+	if err != nil || len(s.stack) == 0 {
+		return next, err
+	}
+	s.offset = next
+	return s.scan(&m.d)
+}
+
+// name resolves the record name reference at offset. A reference may be to
+// a name anywhere before it, so the first call scans the whole message to
+// build the name table.
+func (m *viewMessage) name(offset int) (string, error) {
+	if !m.loaded {
+		m.loaded = true
+		d := decoder{data: m.d.data, opts: m.d.opts}
+		s := scanner{names: true}
+		s.reset()
+		_, m.namesErr = s.scan(&d)
+		m.names = d.names
+	}
+	if m.namesErr != nil {
+		return "", m.namesErr
+	}
+	ref, next, err := m.d.readVarint(offset, TypeRecord)
+	if err != nil {
+		return "", err
+	}
+	if ref > 0 {
+		// The scan checked every reference against the names before it
+		return m.names[ref-1], nil
+	}
+	name, _, err := m.d.readText(next, TypeRecord)
+	return name, err
+}
@@ -0,0 +1,273 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"unsafe"
+)
+
+// TestView tests reading values of each kind of container
+func TestView(t *testing.T) {
+	data, err := Encode(NewDataInput(
+		"foo",
+		int32(42),
+		nil,
+		NewMap(MapEntry{"k", int32(-300)}, MapEntry{int32(1), "one"}),
+		NewRecord("row", Field{"id", int32(7)}, Field{"name", "alice"}),
+		NewDataInput(NewRecord("row", Field{"name", "bob"}), TypedNull{TypeString}),
+	))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	v := NewView(data)
+	if v.Type() != TypeDataInput || v.Len() != 6 {
+		t.Fatalf("top level is %s of %d", typeName(v.Type()), v.Len())
+	}
+	if s, err := v.Index(0).String(); err != nil || s != "foo" {
+		t.Errorf("Index(0) = %q, %v", s, err)
+	}
+	if n, err := v.Index(1).Int32(); err != nil || n != 42 || v.Index(1).Type() != TypeInt32 {
+		t.Errorf("Index(1) = %d, %v", n, err)
+	}
+	if null := v.Index(2); null.Type() != TypeNull || null.Err() != nil {
+		t.Errorf("Index(2) is %s, %v", typeName(null.Type()), null.Err())
+	}
+	if s, err := v.Index(2).String(); err != nil || s != "" {
+		t.Errorf("null String = %q, %v", s, err)
+	}
+
+	m := v.Index(3)
+	if m.Type() != TypeMap || m.Len() != 2 {
+		t.Fatalf("Index(3) is %s of %d", typeName(m.Type()), m.Len())
+	}
+	key, _ := m.Key(0).String()
+	n, _ := m.Index(0).Int32()
+	one, _ := m.Index(1).String()
+	if k, _ := m.Key(1).Int32(); key != "k" || n != -300 || k != 1 || one != "one" {
+		t.Errorf("map entries %q: %d, %d: %q", key, n, k, one)
+	}
+
+	r := v.Index(4)
+	name, err := r.Name()
+	if err != nil || name != "row" || r.Len() != 2 {
+		t.Fatalf("Index(4) is record %q of %d, %v", name, r.Len(), err)
+	}
+	field, _ := r.Field(1)
+	if s, _ := r.Index(1).String(); field != "name" || s != "alice" {
+		t.Errorf("field 1 is %s: %q", field, s)
+	}
+
+	// The second record refers to names interned by the first
+	inner := v.Index(5).Index(0)
+	name, _ = inner.Name()
+	field, _ = inner.Field(0)
+	if s, _ := inner.Index(0).String(); name != "row" || field != "name" || s != "bob" {
+		t.Errorf("nested record %s{%s: %q}", name, field, s)
+	}
+	if n, err := v.Index(5).Index(1).Int32(); err != nil || n != 0 {
+		t.Errorf("typed null Int32 = %d, %v", n, err)
+	}
+
+	// Elements found once are reached again without skipping
+	if s, _ := v.Index(0).String(); s != "foo" {
+		t.Errorf("Index(0) again = %q", s)
+	}
+}
+
+// TestViewMatchesDecode tests that every string and int32 of the fuzz seeds
+// reads the same through a View as from Decode
+func TestViewMatchesDecode(t *testing.T) {
+	var check func(v View, want interface{})
+	check = func(v View, want interface{}) {
+		if v.Err() != nil {
+			t.Fatalf("View of %s: %v", formatDataInput(want), v.Err())
+		}
+		switch want := want.(type) {
+		case string:
+			if s, err := v.String(); err != nil || s != want {
+				t.Errorf("String = %q, %v, want %q", s, err, want)
+			}
+		case int32:
+			if n, err := v.Int32(); err != nil || n != want {
+				t.Errorf("Int32 = %d, %v, want %d", n, err, want)
+			}
+		case nil:
+			if v.Type() != TypeNull {
+				t.Errorf("Type = %s, want null", typeName(v.Type()))
+			}
+		case *DataInput:
+			if v.Len() != want.Len() {
+				t.Fatalf("Len = %d, want %d", v.Len(), want.Len())
+			}
+			for i, e := range want.Elements() {
+				check(v.Index(i), e)
+			}
+		case *Map:
+			if v.Len() != want.Len() {
+				t.Fatalf("Len = %d, want %d", v.Len(), want.Len())
+			}
+			for i, e := range want.Entries() {
+				check(v.Key(i), e.Key)
+				check(v.Index(i), e.Value)
+			}
+		case *Record:
+			if name, err := v.Name(); err != nil || name != want.Name() || v.Len() != want.Len() {
+				t.Fatalf("record %q of %d, %v, want %q of %d", name, v.Len(), err, want.Name(), want.Len())
+			}
+			for i, f := range want.Fields() {
+				if name, err := v.Field(i); err != nil || name != f.Name {
+					t.Errorf("Field(%d) = %q, %v, want %q", i, name, err, f.Name)
+				}
+				check(v.Index(i), f.Value)
+			}
+		}
+	}
+	for _, seed := range fuzzSeeds() {
+		want, err := Decode(seed)
+		if err != nil {
+			t.Fatalf("Decode(seed) failed: %v", err)
+		}
+		check(NewView(seed), want)
+	}
+}
+
+// TestViewErrors tests that a View reports errors in the values it reads,
+// and only those
+func TestViewErrors(t *testing.T) {
+	// Values that are skipped are not checked for invalid UTF-8
+	data := []byte{TypeDataInput, 2, TypeString, 2, 0xC3, 0x28, TypeString, 2, 'o', 'k'}
+	if _, err := Decode(data); !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("Decode = %v, want %v", err, ErrInvalidUTF8)
+	}
+	v := NewView(data)
+	if s, err := v.Index(1).String(); err != nil || s != "ok" {
+		t.Errorf("Index(1) = %q, %v", s, err)
+	}
+	if _, err := v.Index(0).String(); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Index(0) = %v, want %v", err, ErrInvalidUTF8)
+	}
+
+	tests := []struct {
+		name string
+		read func() error
+		want error
+	}{
+		{"Wrong type", func() error { _, err := v.Index(1).Int32(); return err }, ErrUnexpectedType},
+		{"Not a container", func() error { return v.Index(1).Index(0).Err() }, ErrUnexpectedType},
+		{"Not a map", func() error { return v.Key(0).Err() }, ErrUnexpectedType},
+		{"Not a record", func() error { _, err := v.Name(); return err }, ErrUnexpectedType},
+		{"Truncated", func() error {
+			return NewView([]byte{TypeDataInput, 3, TypeNull, TypeString, 5, 'a', TypeNull}).Index(2).Err()
+		}, ErrTruncated},
+		{"Count", func() error { return NewView([]byte{TypeDataInput, 9, TypeNull}).Err() }, ErrTruncated},
+		{"Unknown tag", func() error { return NewView([]byte{TypeDataInput, 1, 0x40}).Index(0).Err() }, ErrUnknownTag},
+		{"Overflow", func() error {
+			_, err := NewView([]byte{TypeVarInt32, 0xFF, 0xFF, 0xFF, 0xFF, 0x1F}).Int32()
+			return err
+		}, ErrInvalidValue},
+		{"Name reference", func() error {
+			_, err := NewView([]byte{TypeRecord, 0, 1, 'r', 1, 5, TypeNull}).Field(0)
+			return err
+		}, ErrInvalidValue},
+		{"Depth", func() error {
+			opts := DefaultDecoderOptions()
+			opts.MaxDepth = 2
+			nested, _ := Encode(NewDataInput(NewDataInput(NewDataInput())))
+			return NewViewWithOptions(nested, opts).Index(0).Index(0).Err()
+		}, ErrLimitExceeded},
+		{"Size", func() error {
+			opts := DefaultDecoderOptions()
+			opts.MaxMessageBytes = 4
+			return NewViewWithOptions(data, opts).Err()
+		}, ErrLimitExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.read(); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	for _, i := range []int{-1, 2} {
+		if err := v.Index(i).Err(); err == nil || v.Index(i).Type() != TypeNull {
+			t.Errorf("Index(%d) = %v, want an out of range error", i, err)
+		}
+	}
+}
+
+// TestViewZeroCopy tests that strings share the input only when asked to
+func TestViewZeroCopy(t *testing.T) {
+	data, _ := Encode(NewDataInput("hello, world"))
+	text := &data[bytes.Index(data, []byte("hello"))]
+
+	s, _ := NewView(data).Index(0).String()
+	if unsafe.StringData(s) == text {
+		t.Error("String shares the input without ZeroCopyStrings")
+	}
+	opts := DefaultDecoderOptions()
+	opts.ZeroCopyStrings = true
+	s, _ = NewViewWithOptions(data, opts).Index(0).String()
+	if s != "hello, world" || unsafe.StringData(s) != text {
+		t.Errorf("String = %q, want the input's memory", s)
+	}
+
+	v, err := DecodeWithOptions(data, opts)
+	if err != nil {
+		t.Fatalf("DecodeWithOptions failed: %v", err)
+	}
+	if s := v.(*DataInput).Elements()[0].(string); unsafe.StringData(s) != text {
+		t.Error("Decode with ZeroCopyStrings returned a copy")
+	}
+}
+
+// BenchmarkViewRead2Of1000 measures reading two fields of a 1000 element
+// message through a View
+func BenchmarkViewRead2Of1000(b *testing.B) {
+	data := []byte(benchmarkFields(b))
+	opts := DefaultDecoderOptions()
+	opts.ZeroCopyStrings = true
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v := NewViewWithOptions(data, opts)
+		id, err := v.Index(0).Int32()
+		if err != nil {
+			b.Fatal(err)
+		}
+		name, err := v.Index(999).String()
+		if err != nil || id != 0 || name != "field 999" {
+			b.Fatalf("read %d, %q, %v", id, name, err)
+		}
+	}
+}
+
+// BenchmarkDecodeRead2Of1000 measures the same reads after decode
+func BenchmarkDecodeRead2Of1000(b *testing.B) {
+	encoded := benchmarkFields(b)
+	b.SetBytes(int64(len(encoded)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		elements := decode(encoded).(*DataInput).Elements()
+		if elements[0] != int32(0) || elements[999] != "field 999" {
+			b.Fatal("fields not found")
+		}
+	}
+}
+
+// benchmarkFields returns a message of 1000 fields alternating between
+// int32s and strings
+func benchmarkFields(b *testing.B) string {
+	fields := NewDataInput()
+	for i := 0; i < 1000; i++ {
+		if i%2 == 0 {
+			fields.Append(int32(i))
+		} else {
+			fields.Append(fmt.Sprintf("field %d", i))
+		}
+	}
+	return encode(fields)
+}